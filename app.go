@@ -1,83 +1,80 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/mercadolibre/jsonschema_test/bic"
 	"github.com/mercadolibre/jsonschema_test/jschema"
 	"github.com/mercadolibre/jsonschema_test/stopwatch"
-
-	"github.com/xeipuuv/gojsonschema"
+	"github.com/mercadolibre/jsonschema_test/validation"
 )
 
 var count = 1000
 
 var fileRelativePath = "./document.json"
 
-func main() {
+var engines = flag.String("engine", "jschema,bic", "comma separated engines to compare: bic, stream or jschema")
 
-	fmt.Printf("Test reading and validate de case file %s %v times \n", fileRelativePath, count)
+var schemaRoot = flag.String("schemas", "./jschema", "directory of the schemas named by the routing file")
 
-	testJsonSchema()
+var routes = flag.String("routes", "./routing.json", "routing file picking the jschema schema by entity and version, ./jschema/schema.json for every document when empty")
 
-	testBic()
+func main() {
+	flag.Parse()
+	bic.DefaultLogger = bic.NopLogger{}
 
-}
+	fmt.Printf("Test reading and validate de case file %s %v times \n", fileRelativePath, count)
 
-func testJsonSchema() {
-	schemaLoader := gojsonschema.NewReferenceLoader("file://./jschema/schema.json")
-	schema, err := gojsonschema.NewSchema(schemaLoader)
-	if err != nil {
-		fmt.Println("Error reading schema file")
+	for _, engine := range strings.Split(*engines, ",") {
+		validator, err := loadEngine(engine)
+		if err != nil {
+			fmt.Println(err.Error())
+			continue
+		}
+		testEngine(engine, validator)
 	}
 
-	watch := stopwatch.Start()
-
-	for i := 0; i < count; i++ {
+}
 
-		payloadContent, err := ioutil.ReadFile(fileRelativePath)
+func loadEngine(engine string) (validation.Validator, error) {
+	if engine == validation.EngineJSONSchema && *routes != "" {
+		router, err := jschema.LoadRouter(jschema.NewRegistry(*schemaRoot), *routes)
 		if err != nil {
-			fmt.Println("Error reading document file")
-		}
-
-		valid, err := jschema.ValidateBytes(payloadContent, schema)
-		if valid {
-			//fmt.Println("Valid document")
-			//Unmarshall del file para contemplar este tiempo tambien.
-			_, err = bic.GetPayloadBody("1", payloadContent)
-			if err != nil {
-				fmt.Printf("Invalid unmarsharll , error: %s \n", err)
-			}
-		} else {
-			fmt.Printf("Invalid document, error: %s \n", err)
+			return nil, err
 		}
+		return validation.JSONSchemaRouter(router), nil
 	}
-	watch.Stop()
-	fmt.Printf("Json Schema Tiempo total: %v \n", watch.Milliseconds())
+	return validation.Load(engine, "./bic/config-productor.json", "file://./jschema/schema.json")
 }
 
-// BIC bi-consumers-ship
-func testBic() {
-	config, err := bic.GetProducerConfigFromFile("./bic/config-productor.json")
-	if err != nil {
-		fmt.Println("Error reading config " + err.Error())
-	}
-
+func testEngine(engine string, validator validation.Validator) {
 	watch := stopwatch.Start()
+
 	for i := 0; i < count; i++ {
+
 		payloadContent, err := ioutil.ReadFile(fileRelativePath)
 		if err != nil {
 			fmt.Println("Error reading document file")
 		}
 
-		valid, err := bic.Validate(payloadContent, config)
-		if valid {
+		result := validator.Validate(context.Background(), payloadContent)
+		if result.Valid {
 			//fmt.Println("Valid document")
+			if engine == validation.EngineJSONSchema {
+				//Unmarshall del file para contemplar este tiempo tambien.
+				_, err = bic.GetPayloadBody("1", payloadContent)
+				if err != nil {
+					fmt.Printf("Invalid unmarsharll , error: %s \n", err)
+				}
+			}
 		} else {
-			fmt.Printf("Invalid document, error: %s \n", err)
+			fmt.Printf("Invalid document, error: %s %v \n", result.Message, result.Errors)
 		}
 	}
 	watch.Stop()
-	fmt.Printf("BIC Tiempo total: %v \n", watch.Milliseconds())
+	fmt.Printf("%v Tiempo total: %v \n", engine, watch.Milliseconds())
 }
@@ -0,0 +1,161 @@
+// Package batch validates newline-delimited documents with a pool of workers. Results
+// are emitted in input order, so the output does not depend on the number of workers.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/mercadolibre/jsonschema_test/validation"
+)
+
+// ErrLineTooLong is reported for lines longer than Pool.MaxLineBytes.
+var ErrLineTooLong = errors.New("batch: line too long")
+
+// Result is the outcome of validating one non empty line.
+type Result struct {
+	Line    int
+	Content []byte
+	validation.Result
+}
+
+// Pool validates the lines of a stream concurrently. At most Workers lines are being
+// validated at once and at most twice as many are waiting to be emitted, the reader is
+// paused until the slowest pending line is done.
+type Pool struct {
+	Engine  validation.Validator
+	Workers int
+	// MaxLineBytes discards longer lines without buffering them, 0 means no limit.
+	MaxLineBytes int
+	// KeepLongLines buffers the lines longer than MaxLineBytes to keep them in Content,
+	// they are still reported as too long without being validated.
+	KeepLongLines bool
+}
+
+func NewPool(engine validation.Validator, workers int) *Pool {
+	return &Pool{Engine: engine, Workers: workers}
+}
+
+type job struct {
+	line    int
+	content []byte
+	tooLong bool
+	result  chan Result
+}
+
+// Run validates every non empty line read from r and calls emit with the results in input
+// order. Emit is never called concurrently. Run stops at the first read or emit error, or
+// when ctx is done.
+func (pool *Pool) Run(ctx context.Context, r io.Reader, emit func(Result) error) error {
+	workers := pool.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *job, workers)
+	pending := make(chan *job, 2*workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job.result <- pool.validate(ctx, job)
+			}
+		}()
+	}
+
+	readError := make(chan error, 1)
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+		readError <- pool.read(ctx, r, jobs, pending)
+	}()
+
+	var emitError error
+	for job := range pending {
+		result := <-job.result
+		if emitError == nil {
+			if emitError = emit(result); emitError != nil {
+				cancel()
+			}
+		}
+	}
+	wg.Wait()
+
+	if emitError != nil {
+		return emitError
+	}
+	if err := <-readError; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// read queues the lines for the workers and, in the same order, for the emitter. A job is
+// only queued for the emitter once a worker is sure to receive it.
+func (pool *Pool) read(ctx context.Context, r io.Reader, jobs, pending chan<- *job) error {
+	reader := bufio.NewReader(r)
+	line := 0
+	for {
+		var content []byte
+		var tooLong bool
+		var err error
+		if pool.KeepLongLines {
+			content, _, err = bic.ReadLine(reader, nil, 0)
+			tooLong = pool.MaxLineBytes > 0 && len(content) > pool.MaxLineBytes
+		} else {
+			content, tooLong, err = bic.ReadLine(reader, nil, pool.MaxLineBytes)
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(content) == 0 && !tooLong && err == io.EOF {
+			return nil
+		}
+		line++
+
+		if tooLong || len(bytes.TrimSpace(content)) > 0 {
+			next := &job{line: line, content: content, tooLong: tooLong, result: make(chan Result, 1)}
+			select {
+			case jobs <- next:
+			case <-ctx.Done():
+				return nil
+			}
+			select {
+			case pending <- next:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (pool *Pool) validate(ctx context.Context, job *job) Result {
+	result := Result{Line: job.line, Content: job.content}
+	if job.tooLong {
+		result.Result = validation.Result{
+			Code:    "payload_too_large",
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("line longer than %v bytes", pool.MaxLineBytes),
+			Err:     ErrLineTooLong,
+		}
+		return result
+	}
+	result.Result = pool.Engine.Validate(ctx, job.content)
+	return result
+}
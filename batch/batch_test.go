@@ -0,0 +1,229 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/mercadolibre/jsonschema_test/jschema"
+	"github.com/mercadolibre/jsonschema_test/validation"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func readCorpus(t *testing.T, copies int) []byte {
+	corpus, err := ioutil.ReadFile("../bic/generated/shipmenttest/testdata/corpus.jsonl")
+	if err != nil {
+		t.Fatalf("Error reading corpus %v", err)
+	}
+	var input bytes.Buffer
+	for i := 0; i < copies; i++ {
+		input.Write(corpus)
+		input.WriteString("\n   \n")
+	}
+	return input.Bytes()
+}
+
+func readConfig(t *testing.T) *bic.StructProducerConfig {
+	config, err := bic.GetProducerConfigFromFile("../bic/config-productor.json")
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+	return config
+}
+
+func run(t *testing.T, engine validation.Validator, workers int, input []byte) []string {
+	var lines []string
+	err := NewPool(engine, workers).Run(context.Background(), bytes.NewReader(input), func(result Result) error {
+		lines = append(lines, fmt.Sprintf("%v %v %v %v", result.Line, result.Valid, result.Err, string(result.Content)))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return lines
+}
+
+func TestPoolIsDeterministic(t *testing.T) {
+	input := readCorpus(t, 20)
+	engine := validation.BicStream(readConfig(t), bic.NopLogger{})
+
+	serial := run(t, engine, 1, input)
+	if len(serial) != 18*20 {
+		t.Fatalf("expected %v results, got %v", 18*20, len(serial))
+	}
+	if !strings.HasPrefix(serial[18], "21 ") {
+		t.Errorf("blank lines must still be counted, got %v", serial[18])
+	}
+
+	for _, workers := range []int{2, 8, 32} {
+		parallel := run(t, engine, workers, input)
+		if strings.Join(parallel, "\n") != strings.Join(serial, "\n") {
+			t.Errorf("results with %v workers differ from the serial run", workers)
+		}
+	}
+}
+
+func TestPoolWithBothEngines(t *testing.T) {
+	input := readCorpus(t, 5)
+	schema, err := jschema.Compile(gojsonschema.NewReferenceLoader("file://../jschema/schema.json"))
+	if err != nil {
+		t.Fatalf("Error reading schema %v", err)
+	}
+
+	engines := map[string]validation.Validator{
+		"bic":     validation.Bic(readConfig(t), bic.NopLogger{}),
+		"jschema": validation.JSONSchema(schema),
+	}
+	for name, engine := range engines {
+		verdicts := func(workers int) string {
+			var verdicts []string
+			NewPool(engine, workers).Run(context.Background(), bytes.NewReader(input), func(result Result) error {
+				verdicts = append(verdicts, fmt.Sprintf("%v:%v", result.Line, result.Valid))
+				return nil
+			})
+			return strings.Join(verdicts, " ")
+		}
+		if serial, parallel := verdicts(1), verdicts(8); serial != parallel {
+			t.Errorf("%v: verdicts differ\nserial   %v\nparallel %v", name, serial, parallel)
+		}
+	}
+}
+
+func TestPoolLineTooLong(t *testing.T) {
+	input := "{\"id\": \"1\"}\n" + strings.Repeat("x", 100) + "\n{\"id\": \"2\"}"
+	pool := NewPool(validation.Func(func(ctx context.Context, doc []byte) validation.Result {
+		return validation.Result{Valid: true}
+	}), 4)
+	pool.MaxLineBytes = 50
+
+	var results []Result
+	pool.Run(context.Background(), strings.NewReader(input), func(result Result) error {
+		results = append(results, result)
+		return nil
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %v", len(results))
+	}
+	if results[1].Err != ErrLineTooLong || results[1].Status != 413 || results[1].Content != nil {
+		t.Errorf("expected a discarded line, got %+v", results[1])
+	}
+	if string(results[2].Content) != "{\"id\": \"2\"}" || !results[2].Valid {
+		t.Errorf("unexpected last result %+v", results[2])
+	}
+
+	pool.KeepLongLines = true
+	results = nil
+	pool.Run(context.Background(), strings.NewReader(input), func(result Result) error {
+		results = append(results, result)
+		return nil
+	})
+	if len(results) != 3 || results[1].Err != ErrLineTooLong || string(results[1].Content) != strings.Repeat("x", 100) {
+		t.Errorf("expected the kept line, got %+v", results)
+	}
+}
+
+// lineReader returns one line per Read, so it counts the lines the pool has read.
+type lineReader struct {
+	read int32
+}
+
+func (reader *lineReader) Read(p []byte) (int, error) {
+	atomic.AddInt32(&reader.read, 1)
+	return copy(p, "{}\n"), nil
+}
+
+// pausedReader is a lineReader that closes full once it has returned limit lines, and
+// reports the lines read past limit before release is closed.
+type pausedReader struct {
+	lineReader
+	limit   int32
+	full    chan struct{}
+	release chan struct{}
+	extra   chan int32
+}
+
+func (reader *pausedReader) Read(p []byte) (int, error) {
+	read := atomic.AddInt32(&reader.read, 1)
+	if read == reader.limit {
+		close(reader.full)
+	} else if read > reader.limit {
+		select {
+		case <-reader.release:
+		default:
+			select {
+			case reader.extra <- read:
+			default: //An extra read is already reported
+			}
+		}
+	}
+	return copy(p, "{}\n"), nil
+}
+
+func TestPoolAppliesBackpressure(t *testing.T) {
+	const workers = 4
+	release := make(chan struct{})
+	started := make(chan struct{}, workers)
+	engine := validation.Func(func(ctx context.Context, doc []byte) validation.Result {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return validation.Result{Valid: true}
+	})
+
+	// Lines held by the workers, the jobs queue and the reader itself, the pending queue
+	// has room for all of them
+	reader := &pausedReader{limit: 2*workers + 1, full: make(chan struct{}), release: release, extra: make(chan int32, 1)}
+	stop := errors.New("stop")
+	done := make(chan error)
+	go func() {
+		done <- NewPool(engine, workers).Run(context.Background(), reader, func(Result) error {
+			return stop
+		})
+	}()
+
+	for i := 0; i < workers; i++ {
+		<-started
+	}
+	select {
+	case <-reader.full:
+	case read := <-reader.extra:
+		t.Errorf("reader was not paused, read %v lines", read)
+	}
+
+	close(release)
+	if err := <-done; err != stop {
+		t.Errorf("expected the emit error, got %v", err)
+	}
+	select {
+	case read := <-reader.extra:
+		t.Errorf("reader was not paused, read %v lines", read)
+	default:
+	}
+}
+
+func TestPoolStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	engine := validation.Func(func(ctx context.Context, doc []byte) validation.Result {
+		return validation.Result{Valid: true}
+	})
+
+	emitted := 0
+	err := NewPool(engine, 2).Run(ctx, &lineReader{}, func(Result) error {
+		if emitted++; emitted == 10 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package bic
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
@@ -29,3 +30,38 @@ func NewPostToReprocessingQueueFail(message string, err error) apierrors.ApiErro
 	}
 	return apierrors.NewApiError(message, "failure while posting to reprocessing queue", http.StatusInternalServerError, cause)
 }
+
+func NewPayloadTooLargeApiError(message string, err error) apierrors.ApiError {
+	cause := apierrors.CauseList{}
+	if err != nil {
+		cause = append(cause, err.Error())
+	}
+	return apierrors.NewApiError(message, "payload_too_large", http.StatusRequestEntityTooLarge, cause)
+}
+
+func NewUnprocessableEntityApiError(message string, err error) apierrors.ApiError {
+	cause := apierrors.CauseList{}
+	if err != nil {
+		cause = append(cause, err.Error())
+	}
+	return apierrors.NewApiError(message, "unprocessable_entity", http.StatusUnprocessableEntity, cause)
+}
+
+// NewLimitApiError maps a LimitError to its HTTP semantics: the raw size is a 413 and
+// any structural limit is a 422.
+func NewLimitApiError(limitError *LimitError) apierrors.ApiError {
+	if limitError.Limit == "max_bytes" {
+		return NewPayloadTooLargeApiError("payload too large", limitError)
+	}
+	return NewUnprocessableEntityApiError("payload exceeds structural limits", limitError)
+}
+
+// NewContextApiError reports a validation interrupted by its context. A deadline is
+// reported as validation_timeout and any other cancellation as validation_canceled.
+func NewContextApiError(err error) apierrors.ApiError {
+	code := "validation_canceled"
+	if err == context.DeadlineExceeded {
+		code = "validation_timeout"
+	}
+	return apierrors.NewApiError("validation interrupted", code, http.StatusRequestTimeout, apierrors.CauseList{err.Error()})
+}
@@ -0,0 +1,229 @@
+package bic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+)
+
+// LineResult is the outcome of validating one line of a newline-delimited batch.
+type LineResult struct {
+	Line    int      `json:"line"`
+	ID      string   `json:"id,omitempty"`
+	Entity  string   `json:"entity,omitempty"`
+	Valid   bool     `json:"valid"`
+	Code    string   `json:"code,omitempty"`
+	Status  int      `json:"status,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// BatchSummary counts the results of a batch by error code.
+type BatchSummary struct {
+	Total   int            `json:"total"`
+	Valid   int            `json:"valid"`
+	Invalid int            `json:"invalid"`
+	ByCode  map[string]int `json:"by_code"`
+}
+
+// NewBatchSummary returns an empty summary, ready to Add results.
+func NewBatchSummary() BatchSummary {
+	return BatchSummary{ByCode: make(map[string]int)}
+}
+
+func (summary *BatchSummary) Add(result LineResult) {
+	summary.Total++
+	if result.Valid {
+		summary.Valid++
+		return
+	}
+	summary.Invalid++
+	summary.ByCode[result.Code]++
+}
+
+// BatchScanner validates a newline-delimited stream of payloads one line at a time. Only
+// the current line is kept in memory, and lines longer than the configured max_bytes
+// limit are discarded and reported as payload_too_large.
+type BatchScanner struct {
+	validator *StreamValidator
+	reader    *bufio.Reader
+	maxBytes  int
+	line      int
+	content   []byte
+	result    LineResult
+	summary   BatchSummary
+	err       error
+}
+
+// ValidateStream returns a scanner over the newline-delimited payloads read from r.
+//
+//	scanner := bic.ValidateStream(file, config)
+//	for scanner.Scan() {
+//		result := scanner.Result()
+//	}
+//	summary, err := scanner.Summary(), scanner.Err()
+func ValidateStream(r io.Reader, config *StructProducerConfig) *BatchScanner {
+	return NewStreamValidator(config, nil).ValidateStream(r)
+}
+
+func (validator *StreamValidator) ValidateStream(r io.Reader) *BatchScanner {
+	return &BatchScanner{
+		validator: validator,
+		reader:    bufio.NewReader(r),
+		maxBytes:  validator.Config.Limits().MaxBytes,
+		summary:   NewBatchSummary(),
+	}
+}
+
+// Scan validates the next non empty line. It returns false at the end of the input or on
+// a read error, which is then reported by Err.
+func (scanner *BatchScanner) Scan() bool {
+	for {
+		content, tooLong, err := ReadLine(scanner.reader, scanner.content[:0], scanner.maxBytes)
+		scanner.content = content
+		if err != nil && (err != io.EOF || (len(content) == 0 && !tooLong)) {
+			if err != io.EOF {
+				scanner.err = err
+			}
+			scanner.content = nil
+			return false
+		}
+		scanner.line++
+
+		if tooLong {
+			limitError := &LimitError{Limit: "max_bytes", Max: scanner.maxBytes}
+			scanner.setResult(nil, nil, NewLimitApiError(limitError))
+			return true
+		}
+		if len(bytes.TrimSpace(content)) == 0 {
+			if err == io.EOF {
+				scanner.content = nil
+				return false
+			}
+			continue
+		}
+
+		state, apiError := scanner.validator.scanState(context.Background(), content)
+		scanner.setResult(content, state, apiError)
+		return true
+	}
+}
+
+// ReadLine appends the next line of reader to buffer and returns it without its
+// terminator. Lines beyond maxBytes are drained from the reader without being buffered,
+// they are reported by tooLong with an empty line. A maxBytes of 0 means no limit.
+func ReadLine(reader *bufio.Reader, buffer []byte, maxBytes int) (line []byte, tooLong bool, err error) {
+	content := buffer
+	for {
+		fragment, err := reader.ReadSlice('\n')
+		if !tooLong {
+			content = append(content, fragment...)
+			if maxBytes > 0 && len(bytes.TrimRight(content[len(buffer):], "\r\n")) > maxBytes {
+				tooLong = true
+				content = buffer
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return bytes.TrimRight(content[len(buffer):], "\r\n"), tooLong, err
+	}
+}
+
+func (scanner *BatchScanner) setResult(content []byte, state *streamScan, apiError apierrors.ApiError) {
+	result := LineResult{Line: scanner.line, Valid: apiError == nil}
+	if state != nil {
+		result.ID = state.id
+		result.Entity = state.entity
+	}
+	if apiError != nil {
+		setErrorFields(&result, apiError)
+	}
+	scanner.content = content
+	scanner.result = result
+	scanner.summary.Add(result)
+}
+
+// Result returns the result of the line read by the last call to Scan.
+func (scanner *BatchScanner) Result() LineResult {
+	return scanner.result
+}
+
+// Bytes returns the content of the line read by the last call to Scan. The slice is only
+// valid until the next call to Scan.
+func (scanner *BatchScanner) Bytes() []byte {
+	return scanner.content
+}
+
+func (scanner *BatchScanner) Summary() BatchSummary {
+	return scanner.summary
+}
+
+func (scanner *BatchScanner) Err() error {
+	return scanner.err
+}
+
+func setErrorFields(result *LineResult, apiError apierrors.ApiError) {
+	result.Code = apiError.Code()
+	result.Status = apiError.Status()
+	result.Message = apiError.Message()
+	for _, cause := range apiError.Cause() {
+		result.Errors = append(result.Errors, fmt.Sprintf("%v", cause))
+	}
+}
+
+// PeekEnvelope reads the string id and entity of a payload, skipping every other value
+// instead of validating it. Like json.Unmarshal, keys match case insensitively and the
+// last occurrence wins.
+func PeekEnvelope(content []byte) (id, entity string) {
+	fields := PeekFields(content, "id", "entity")
+	return fields[0], fields[1]
+}
+
+// PeekFields reads the top level string values of keys, in the same order, the same way
+// as PeekEnvelope. Missing keys and values that are not strings are empty.
+func PeekFields(content []byte, keys ...string) []string {
+	fields := make([]string, len(keys))
+	for i, value := range PeekValues(content, keys...) {
+		fields[i], _ = value.(string)
+	}
+	return fields
+}
+
+// PeekValues reads the top level values of keys like PeekFields, keeping their type:
+// strings, float64 and bool values, the opening json.Delim of objects and arrays, and
+// nil for missing keys. Like json.Unmarshal, null leaves the previous value.
+func PeekValues(content []byte, keys ...string) []interface{} {
+	values := make([]interface{}, len(keys))
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return values
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil || token == json.Delim('}') {
+			return values
+		}
+		key, _ := token.(string)
+		value, err := decoder.Token()
+		if err != nil {
+			return values
+		}
+		if value != nil {
+			for i := range keys {
+				if strings.EqualFold(key, keys[i]) {
+					values[i] = value
+				}
+			}
+		}
+		if err := skipValue(decoder, value); err != nil {
+			return values
+		}
+	}
+}
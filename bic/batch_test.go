@@ -0,0 +1,99 @@
+package bic
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestValidateStream(t *testing.T) {
+	captureLogs(t)
+
+	config, _ := GetProducerConfig("1", mandatoryConfig)
+	config.Limits = &Limits{MaxBytes: 200}
+
+	input := strings.Join([]string{
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}}`,
+		``,
+		`{"entity": "SHIPMENT_TEST", "id": "2", "metrics": {"handling_time": {"estimated_days": "1"}, "lead_time": {"estimated_days": 3}}}`,
+		`{"entity": "ORDER", "id": "3", "metrics": {}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "4", "metrics": {"handling_time": {"flags": [` + strings.Repeat("1, ", 100) + `1]}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "5"`,
+		"{\"entity\": \"SHIPMENT_TEST\", \"id\": \"6\", \"metrics\": {\"handling_time\": {\"estimated_days\": 1}, \"lead_time\": {\"estimated_days\": 3}}}\r",
+		`{"entity": "SHIPMENT_TEST", "id": "7", "metrics": {}}`,
+	}, "\n")
+
+	expected := []LineResult{
+		{Line: 1, ID: "1", Entity: "SHIPMENT_TEST", Valid: true},
+		{Line: 3, ID: "2", Entity: "SHIPMENT_TEST", Code: "not_acceptable"},
+		{Line: 4, ID: "3", Entity: "ORDER", Code: "unauthorized_scopes"},
+		{Line: 5, Code: "payload_too_large"},
+		{Line: 6, ID: "5", Entity: "SHIPMENT_TEST", Code: "internal_server_error"},
+		{Line: 7, ID: "6", Entity: "SHIPMENT_TEST", Valid: true},
+		{Line: 8, ID: "7", Entity: "SHIPMENT_TEST", Code: "not_acceptable"},
+	}
+
+	scanner := ValidateStream(strings.NewReader(input), config)
+	var results []LineResult
+	for scanner.Scan() {
+		results = append(results, scanner.Result())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("expected %v results, got %+v", len(expected), results)
+	}
+	for i, result := range results {
+		want := expected[i]
+		if result.Line != want.Line || result.ID != want.ID || result.Entity != want.Entity || result.Valid != want.Valid || result.Code != want.Code {
+			t.Errorf("line %v: expected %+v, got %+v", want.Line, want, result)
+		}
+		if !result.Valid && len(result.Errors) == 0 && result.Code != "unauthorized_scopes" {
+			t.Errorf("line %v: expected error causes", want.Line)
+		}
+	}
+
+	summary := scanner.Summary()
+	if summary.Total != 7 || summary.Valid != 2 || summary.Invalid != 5 || summary.ByCode["not_acceptable"] != 2 || summary.ByCode["payload_too_large"] != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func TestPeekEnvelope(t *testing.T) {
+	content := []byte(`{"metrics": {"id": "nested"}, "ID": "7", "Entity": "SHIPMENT_TEST", "tags": [{"entity": "x"}], "entity": null}`)
+	if id, entity := PeekEnvelope(content); id != "7" || entity != "SHIPMENT_TEST" {
+		t.Errorf("unexpected envelope %q %q", id, entity)
+	}
+	if id, entity := PeekEnvelope([]byte(`{"id": "1", "entity": `)); id != "1" || entity != "" {
+		t.Errorf("expected the fields read before the syntax error, got %q %q", id, entity)
+	}
+	if id, entity := PeekEnvelope([]byte(`[{"id": "1"}]`)); id != "" || entity != "" {
+		t.Errorf("unexpected envelope %q %q", id, entity)
+	}
+	if fields := PeekFields(content, "version", "entity"); fields[0] != "" || fields[1] != "SHIPMENT_TEST" {
+		t.Errorf("unexpected fields %q", fields)
+	}
+	if values := PeekValues(content, "tags", "id", "version"); values[0] != json.Delim('[') || values[1] != "7" || values[2] != nil {
+		t.Errorf("unexpected values %v", values)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader("first\r\n"+strings.Repeat("x", 40)+"\nlast"), 16)
+	buffer := []byte("kept")
+
+	line, tooLong, err := ReadLine(reader, buffer, 10)
+	if string(line) != "first" || tooLong || err != nil || string(buffer) != "kept" {
+		t.Errorf("unexpected first line %q %v %v", line, tooLong, err)
+	}
+	if line, tooLong, err = ReadLine(reader, nil, 10); len(line) != 0 || !tooLong || err != nil {
+		t.Errorf("expected a line too long, got %q %v %v", line, tooLong, err)
+	}
+	if line, tooLong, err = ReadLine(reader, nil, 10); string(line) != "last" || tooLong || err != io.EOF {
+		t.Errorf("unexpected last line %q %v %v", line, tooLong, err)
+	}
+}
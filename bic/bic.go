@@ -1,18 +1,22 @@
 package bic
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"math/big"
 	"regexp"
 	"strings"
 
 	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
-	"github.com/mercadolibre/go-meli-toolkit/goutils/logger"
+	"github.com/mercadolibre/jsonschema_test/stopwatch"
 )
 
+// Deprecated: VALIDATED_METRIC is no longer used, the state of the metric leaf validation
+// is kept per call so that payloads can be validated concurrently.
 var VALIDATED_METRIC = false
 
 func GetProducerConfigFromFile(configFilePath string) (*StructProducerConfig, error) {
@@ -35,7 +39,7 @@ func GetProducerConfig(token string, configBytes []byte) (*StructProducerConfig,
 
 	//Unmarshalling body into StructPayload
 	if unmarshallError := json.Unmarshal(configBytes, config); unmarshallError != nil {
-		logger.Error("Error unmarshalling body from feed into struct config. Body: "+string(json.RawMessage(configBytes)), unmarshallError)
+		DefaultLogger.Error("Error unmarshalling body from feed into struct config", unmarshallError, F("body", redactBody(configBytes)))
 		err := apierrors.NewInternalServerApiError("error unmarshalling body from feed into struct payload", unmarshallError)
 		return nil, err
 	}
@@ -44,21 +48,41 @@ func GetProducerConfig(token string, configBytes []byte) (*StructProducerConfig,
 }
 
 func Validate(payloadContent []byte, producerConfig *StructProducerConfig) (bool, error) {
+	return validate(context.Background(), DefaultLogger, payloadContent, producerConfig)
+}
+
+// ValidateContext behaves like Validate but stops walking the payload as soon as ctx is
+// done, returning a validation_timeout or validation_canceled error.
+func ValidateContext(ctx context.Context, payloadContent []byte, producerConfig *StructProducerConfig) (bool, error) {
+	return validate(ctx, DefaultLogger, payloadContent, producerConfig)
+}
+
+func validate(ctx context.Context, log Logger, payloadContent []byte, producerConfig *StructProducerConfig) (bool, error) {
+	defer stopwatch.Track(ctx)()
+
+	if ctx.Err() != nil {
+		return false, NewContextApiError(ctx.Err())
+	}
 
 	token := "1"
 
+	limits := DefaultLimits
+	if producerConfig.Limits != nil {
+		limits = *producerConfig.Limits
+	}
+
 	//Getting a StructPayload from request body
-	payload, err := GetPayloadBody(token, payloadContent)
+	payload, err := getPayloadBody(ctx, log, token, payloadContent, limits)
 	if err != nil {
 		return false, err
 	}
 
-	_, err = validatePayloadBody(token, payload)
+	_, err = validatePayloadBody(log, token, payload)
 	if err != nil {
 		return false, err
 	}
 
-	_, validationError := validatePayload(payload, producerConfig)
+	_, validationError := validatePayload(ctx, log, payload, producerConfig)
 	if validationError != nil {
 		return false, validationError
 	}
@@ -67,24 +91,42 @@ func Validate(payloadContent []byte, producerConfig *StructProducerConfig) (bool
 }
 
 func GetPayloadBody(token string, jsonBytes []byte) (*StructPayload, apierrors.ApiError) {
+	return GetPayloadBodyWithLimits(token, jsonBytes, DefaultLimits)
+}
 
-	payload := new(StructPayload)
+func GetPayloadBodyWithLimits(token string, jsonBytes []byte, limits Limits) (*StructPayload, apierrors.ApiError) {
+	return getPayloadBody(context.Background(), DefaultLogger, token, jsonBytes, limits)
+}
 
-	//Unmarshalling body into StructPayload
-	if unmarshallError := json.Unmarshal(jsonBytes, payload); unmarshallError != nil {
-		logger.Error("Error unmarshalling body from feed into struct payload. Body: "+string(json.RawMessage(jsonBytes)), unmarshallError)
-		err := apierrors.NewInternalServerApiError("error unmarshalling body from feed into struct payload", unmarshallError)
+func getPayloadBody(ctx context.Context, log Logger, token string, jsonBytes []byte, limits Limits) (*StructPayload, apierrors.ApiError) {
+
+	//Decoding the payload while checking its limits, before the metrics tree grows past them
+	payload, decodeError := decodePayload(ctx, jsonBytes, limits)
+	if limitError, isLimit := decodeError.(*LimitError); isLimit {
+		log.Error("Payload exceeds limits", limitError)
+		return nil, NewLimitApiError(limitError)
+	} else if decodeError != nil && decodeError == ctx.Err() {
+		log.Error("Validation interrupted", decodeError)
+		return nil, NewContextApiError(decodeError)
+	} else if decodeError != nil {
+		log.Error("Error unmarshalling body from feed into struct payload", decodeError, F("body", redactBody(jsonBytes)))
+		err := apierrors.NewInternalServerApiError("error unmarshalling body from feed into struct payload", decodeError)
 		return nil, err
 	}
 
 	return payload, nil
 }
 
-func validatePayloadBody(token string, payload *StructPayload) (*StructPayload, apierrors.ApiError) {
+// ValidatePayloadBody checks the payload envelope: id, entity and a non null metrics block.
+func ValidatePayloadBody(token string, payload *StructPayload) (*StructPayload, apierrors.ApiError) {
+	return validatePayloadBody(DefaultLogger, token, payload)
+}
+
+func validatePayloadBody(log Logger, token string, payload *StructPayload) (*StructPayload, apierrors.ApiError) {
 
 	//Validate that payload keys are not nil
 	if payloadKeysError := payloadKeysValidation(payload.ID, payload.Entity); payloadKeysError != nil {
-		logger.Error("Error validating payload keys", payloadKeysError)
+		log.Error("Error validating payload keys", payloadKeysError)
 		err := apierrors.NewInternalServerApiError("payload keys validation", payloadKeysError)
 		return nil, err
 	}
@@ -92,7 +134,7 @@ func validatePayloadBody(token string, payload *StructPayload) (*StructPayload,
 	//Validate that metrics block is not nil. It could be {} if producer do not want to save new metrics.
 	if payload.Metrics == nil {
 		payloadMetricsBlockError := errors.New("Metrics block can't be null. If you don't want to post any metrics, send an empty map {} in metrics instead of null")
-		logger.Error("Error validating payload metrics block", payloadMetricsBlockError)
+		log.Error("Error validating payload metrics block", payloadMetricsBlockError)
 		err := apierrors.NewInternalServerApiError("payload metrics block validation", payloadMetricsBlockError)
 		return nil, err
 	}
@@ -101,35 +143,62 @@ func validatePayloadBody(token string, payload *StructPayload) (*StructPayload,
 	return payload, nil
 }
 
-func validatePayload(payload *StructPayload, producerConfig *StructProducerConfig) (*StructPayload, apierrors.ApiError) {
-	token := payload.ProducerToken
-	if !strings.EqualFold(producerConfig.Entity, payload.Entity) {
+func validatePayload(ctx context.Context, log Logger, payload *StructPayload, producerConfig *StructProducerConfig) (*StructPayload, apierrors.ApiError) {
+	entityConfig, err := checkEntityAndStatus(log, payload, producerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if entityConfig.MandatoryFields != nil {
+		if mandatoryFieldsError := checkMandatoryFields(entityConfig.MandatoryFields, payload.Metrics); mandatoryFieldsError != nil {
+			return nil, newMandatoryFieldsApiError(log, payload, entityConfig, mandatoryFieldsError)
+		}
+	}
+
+	newPayload, producerConfigError := checkProducerConfig(ctx, log, entityConfig, payload)
+	if producerConfigError != nil {
+		return nil, newMetricsApiError(ctx, log, payload, producerConfigError)
+	}
+	return newPayload, nil
+}
+
+func checkEntityAndStatus(log Logger, payload *StructPayload, producerConfig *StructProducerConfig) (*EntityConfig, apierrors.ApiError) {
+	token := redactToken(payload.ProducerToken)
+	id := redactID(payload.ID)
+	entityConfig, entityFound := producerConfig.FindEntity(payload.Entity)
+	if !entityFound {
 		err := apierrors.NewUnauthorizedApiError("provided entity does not match the one in the producer configuration")
-		logger.Errorf("Unauthorized provided entity [id: %v][entity: %v][configurationEntity: %v][token: %v]", err, payload.ID, payload.Entity, producerConfig.Entity, token)
+		log.Error("Unauthorized provided entity", err, F("id", id), F("entity", payload.Entity), F("configurationEntity", strings.Join(producerConfig.EntityNames(), ",")), F("token", token))
 		return nil, err
 	}
 
 	if !strings.EqualFold(producerConfig.Status, "enabled") {
 		err := apierrors.NewUnauthorizedApiError("producer not enabled")
-		logger.Errorf("Unauthorized producer [id: %v][entity: %v][token: %v]", err, payload.ID, payload.Entity, token)
+		log.Error("Unauthorized producer", err, F("id", id), F("entity", payload.Entity), F("token", token))
 		return nil, err
 	}
 
-	if producerConfig.MandatoryFields != nil {
-		if mandatoryFieldsError := checkMandatoryFields(producerConfig.MandatoryFields, payload.Metrics); mandatoryFieldsError != nil {
-			err := NewNotAcceptableApiError("missing a few mandatory fields", mandatoryFieldsError)
-			logger.Errorf("Missing a few mandatory fields [id: %v][entity: %v][configurationEntity: %v][token: %v]", mandatoryFieldsError, payload.ID, payload.Entity, token)
-			return nil, err
-		}
-	}
+	return entityConfig, nil
+}
 
-	newPayload, producerConfigError := checkProducerConfig(producerConfig, payload)
-	if producerConfigError != nil {
-		err := NewNotAcceptableApiError("provided metrics do not match the ones in the producer configuration", producerConfigError)
-		logger.Errorf("Not acceptable provided metrics [id: %v][entity: %v][token: %v]", producerConfigError, payload.ID, payload.Entity, token)
-		return nil, err
+func newMandatoryFieldsApiError(log Logger, payload *StructPayload, entityConfig *EntityConfig, mandatoryFieldsError error) apierrors.ApiError {
+	err := NewNotAcceptableApiError("missing a few mandatory fields", mandatoryFieldsError)
+	log.Error("Missing a few mandatory fields", mandatoryFieldsError, F("id", redactID(payload.ID)), F("entity", payload.Entity), F("configurationEntity", entityConfig.Entity), F("token", redactToken(payload.ProducerToken)))
+	return err
+}
+
+func newMetricsApiError(ctx context.Context, log Logger, payload *StructPayload, producerConfigError error) apierrors.ApiError {
+	token := redactToken(payload.ProducerToken)
+	id := redactID(payload.ID)
+	if producerConfigError == ctx.Err() {
+		err := NewContextApiError(producerConfigError)
+		log.Error("Validation interrupted", producerConfigError, F("id", id), F("entity", payload.Entity), F("token", token))
+		return err
 	}
-	return newPayload, nil
+
+	err := NewNotAcceptableApiError("provided metrics do not match the ones in the producer configuration", producerConfigError)
+	log.Error("Not acceptable provided metrics", producerConfigError, F("id", id), F("entity", payload.Entity), F("token", token))
+	return err
 }
 
 func payloadKeysValidation(id string, entity string) error {
@@ -144,7 +213,7 @@ func payloadKeysValidation(id string, entity string) error {
 	}
 }
 
-func checkProducerConfig(config *StructProducerConfig, payload *StructPayload) (*StructPayload, error) {
+func checkProducerConfig(ctx context.Context, log Logger, config *EntityConfig, payload *StructPayload) (*StructPayload, error) {
 	newPayload := new(StructPayload)
 	newPayload.ID = payload.ID
 	newPayload.Entity = payload.Entity
@@ -152,21 +221,19 @@ func checkProducerConfig(config *StructProducerConfig, payload *StructPayload) (
 
 	payloadMetrics := payload.Metrics
 	configMetrics := config.AllowedMetrics
+	piiFields := piiFieldSet(config.PIIFields)
 
 	for key, metricsBlock := range payloadMetrics {
 		var pathMetric []string              //Always create the path root of current metrics block
 		pathMetric = append(pathMetric, key) //Appends the first level of the block before calling metrics block validation method
 
-		pathError, err := validateMetricBlock(metricsBlock, configMetrics, &pathMetric, "", nil) //key and value are necessary for recursion inside the validateMetricBlock method, so they are nil in this case
+		pathError, err := validateMetricBlock(ctx, log, metricsBlock, configMetrics, piiFields, &pathMetric, "", nil) //key and value are necessary for recursion inside the validateMetricBlock method, so they are nil in this case
 
+		if err != nil && err == ctx.Err() { //Context errors are returned as is, there is no path to point at
+			return nil, err
+		}
 		if err != nil { //In case validateMetricBlock method returns error, pathError is used for return exact error point at path
-			concatPathError := ""
-			for _, path := range *pathError {
-				concatPathError = concatPathError + path + "."
-			}
-			concatPathError = concatPathError[:len(concatPathError)-1]
-			error := fmt.Errorf("%v at %v", err.Error(), concatPathError)
-			return nil, error
+			return nil, metricPathError(*pathError, err)
 		}
 	}
 
@@ -175,14 +242,17 @@ func checkProducerConfig(config *StructProducerConfig, payload *StructPayload) (
 	return newPayload, nil
 }
 
-func validateMetricBlock(metricsBlock interface{}, configMetrics interface{}, pathMetric *[]string, key string, value interface{}) (*[]string, error) {
+func validateMetricBlock(ctx context.Context, log Logger, metricsBlock interface{}, configMetrics interface{}, piiFields map[string]bool, pathMetric *[]string, key string, value interface{}) (*[]string, error) {
 	subLevelBlock, subLevelIsMap := metricsBlock.(map[string]interface{}) //Validates if an interface{} is a map
 	var validatingError error
 	var pathError *[]string
 	if subLevelIsMap { //If an interface is a map, then iterates it looking for metric leaf before recursion on validateMetricBlock
 		for subLevelKey, subLevelValue := range subLevelBlock {
+			if ctxError := ctx.Err(); ctxError != nil { //Stops walking the payload once the request is done
+				return nil, ctxError
+			}
 			*pathMetric = append(*pathMetric, subLevelKey) //Saves the next level of the block in path
-			pathError, validatingError = validateMetricBlock(subLevelBlock[subLevelKey], configMetrics, pathMetric, subLevelKey, subLevelValue)
+			pathError, validatingError = validateMetricBlock(ctx, log, subLevelBlock[subLevelKey], configMetrics, piiFields, pathMetric, subLevelKey, subLevelValue)
 			if validatingError != nil { //If a error occurs in a recursive call, keeps original cause in all recursive calls
 				return pathError, validatingError
 			}
@@ -193,8 +263,7 @@ func validateMetricBlock(metricsBlock interface{}, configMetrics interface{}, pa
 	_, ValueIsMap := value.(map[string]interface{}) //Validates that value is a metric leaf before calling validatePathAndTypeOfLeafMetric
 
 	if !ValueIsMap && key != "" { //In parallel, validates that key is not missing to avoid a not metric leaf
-		VALIDATED_METRIC = false                                                                                       //Uses a global variable to save the state of metric leaf validation
-		validatedMetric, pathError, error := validatePathAndTypeOfLeafMetric(configMetrics, pathMetric, 0, key, value) //pathPosition is necessary for recursion inside the validatePathAndTypeOfLeafMetric method, so its value must be 0 in this case
+		validatedMetric, pathError, error := validatePathAndTypeOfLeafMetric(log, configMetrics, piiFields, pathMetric, 0, key, value) //pathPosition is necessary for recursion inside the validatePathAndTypeOfLeafMetric method, so its value must be 0 in this case
 		if validatedMetric {
 			return nil, nil
 		}
@@ -209,45 +278,46 @@ func validateMetricBlock(metricsBlock interface{}, configMetrics interface{}, pa
 	}
 }
 
-func validatePathAndTypeOfLeafMetric(configMetricsBlock interface{}, path *[]string, pathPosition int, keyMetric string, valueMetric interface{}) (bool, *[]string, error) {
+func validatePathAndTypeOfLeafMetric(log Logger, configMetricsBlock interface{}, piiFields map[string]bool, path *[]string, pathPosition int, keyMetric string, valueMetric interface{}) (bool, *[]string, error) {
 	subLevelConfigBlock, ok := configMetricsBlock.(map[string]interface{})
 	var err error
+	validatedMetric := false
 
 	if ok && valueMetric != nil {
 		for subLevelConfigKey, subLevelConfigValue := range subLevelConfigBlock {
 			if pathPosition < len(*path) {
 				if (*path)[pathPosition] == subLevelConfigKey {
 					pathPosition++
-					validatedMetric, pathError, recursiveError := validatePathAndTypeOfLeafMetric(subLevelConfigValue, path, pathPosition, keyMetric, valueMetric)
+					validatedMetric, pathError, recursiveError := validatePathAndTypeOfLeafMetric(log, subLevelConfigValue, piiFields, path, pathPosition, keyMetric, valueMetric)
 					err = recursiveError
 					if validatedMetric {
-						return VALIDATED_METRIC, nil, nil
+						return true, nil, nil
 					} else {
-						return VALIDATED_METRIC, pathError, err
+						return false, pathError, err
 					}
 				}
 			} else {
 				pathError := *path
 				err = fmt.Errorf("invalid metric level")
-				logger.Error("invalid metric level", err)
-				return VALIDATED_METRIC, &pathError, err
+				log.Error("invalid metric level", err)
+				return false, &pathError, err
 			}
 		}
 	} else {
 		if valueMetric == nil {
-			VALIDATED_METRIC = true
+			validatedMetric = true
 		} else {
-			err = checkLeavesTypes(valueMetric, configMetricsBlock, keyMetric)
+			err = checkLeavesTypes(log, valueMetric, configMetricsBlock, keyMetric, isPIIPath(piiFields, *path))
 			if err != nil {
 				pathError := *path
-				return VALIDATED_METRIC, &pathError, err
+				return false, &pathError, err
 			} else {
-				VALIDATED_METRIC = true
+				validatedMetric = true
 			}
 		}
 	}
-	if VALIDATED_METRIC {
-		return VALIDATED_METRIC, nil, nil
+	if validatedMetric {
+		return true, nil, nil
 	} else {
 		var pathError []string
 		if pathPosition >= len(*path) {
@@ -256,18 +326,22 @@ func validatePathAndTypeOfLeafMetric(configMetricsBlock interface{}, path *[]str
 			pathError = (*path)[:pathPosition+1]
 		}
 		err = fmt.Errorf("invalid metric name")
-		logger.Error("invalid metric name", err)
-		return VALIDATED_METRIC, &pathError, err
+		log.Error("invalid metric name", err)
+		return false, &pathError, err
 	}
 }
 
-func checkLeavesTypes(metricValue interface{}, typeConfigMetric interface{}, keyMetric string) error {
+func checkLeavesTypes(log Logger, metricValue interface{}, typeConfigMetric interface{}, keyMetric string, masked bool) error {
 	stringType := fmt.Sprintf("%v", typeConfigMetric)
 	if metricTypeChecker(metricValue, stringType) {
 		return nil
 	} else {
-		logger.Debugf("field '%v' with different data type, sent value: '%v'", keyMetric, metricValue)
-		return fmt.Errorf("field '%v' with different data type, sent value: %v", keyMetric, metricValue)
+		sentValue := metricValue
+		if masked {
+			sentValue = maskedValue
+		}
+		log.Debug("field with different data type", F("field", keyMetric), F("sentValue", sentValue))
+		return fmt.Errorf("field '%v' with different data type, sent value: %v", keyMetric, sentValue)
 	}
 }
 
@@ -320,6 +394,41 @@ func flattenMetricsMap(metrics map[string]interface{}, flattenedPaths map[string
 	}
 }
 
+// IsLeafType reports whether a decoded metric value matches a type from allowed_metrics.
+func IsLeafType(metricValue interface{}, leafType string) bool {
+	return metricTypeChecker(metricValue, leafType)
+}
+
+// LeafFormat is the JSON Schema format checking values with IsLeafType. date and time
+// get a bic- prefix, the standard formats with those names are stricter.
+func LeafFormat(leafType string) string {
+	if leafType == "date" || leafType == "time" {
+		return "bic-" + leafType
+	}
+	return leafType
+}
+
+// LeafFormatType is the JSON type a LeafFormat must be paired with: gojsonschema only
+// checks formats on strings and numbers, so the format alone accepts booleans, arrays
+// and objects, which IsLeafType rejects.
+func LeafFormatType(leafType string) string {
+	if leafType == "boolean_number" {
+		return "number"
+	}
+	return "string"
+}
+
+// LeafFormatChecker is the gojsonschema format checker of the leaf type it names.
+type LeafFormatChecker string
+
+func (leafType LeafFormatChecker) IsFormat(input interface{}) bool {
+	if number, ok := input.(*big.Rat); ok {
+		value, _ := number.Float64()
+		return IsLeafType(value, string(leafType))
+	}
+	return IsLeafType(input, string(leafType))
+}
+
 func metricTypeChecker(metricValue interface{}, t string) bool {
 	switch metricValue.(type) {
 	case int:
@@ -361,19 +470,23 @@ func metricTypeChecker(metricValue interface{}, t string) bool {
 	return false //No se pudo identificar el tipo o tipo erroneo
 }
 
+// Leaf formats are not anchored, a value matches when it contains the format.
+var (
+	dateFormat     = regexp.MustCompile("((19|20)..)-(0[1-9]|1[012])-(0[1-9]|1[0-9]|2[0-9]|3[01])")
+	timeFormat     = regexp.MustCompile("(0[0-9]|1[0-9]|2[0-3]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9])")
+	dateTimeFormat = regexp.MustCompile("((19|20)..)-(0[1-9]|1[012])-(0[1-9]|1[0-9]|2[0-9]|3[01])T(0[0-9]|1[0-9]|2[0-3]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9])(.+)")
+)
+
 func dateFormatChecker(s string) bool {
-	re := regexp.MustCompile("((19|20)..)-(0[1-9]|1[012])-(0[1-9]|1[0-9]|2[0-9]|3[01])")
-	return re.MatchString(s)
+	return dateFormat.MatchString(s)
 }
 
 func timeFormatChecker(s string) bool {
-	re := regexp.MustCompile("(0[0-9]|1[0-9]|2[0-3]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9])")
-	return re.MatchString(s)
+	return timeFormat.MatchString(s)
 }
 
 func dateTimeFormatChecker(s string) bool {
-	re := regexp.MustCompile("((19|20)..)-(0[1-9]|1[012])-(0[1-9]|1[0-9]|2[0-9]|3[01])T(0[0-9]|1[0-9]|2[0-3]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9]):(0[0-9]|1[0-9]|2[0-9]|3[0-9]|4[0-9]|5[0-9])(.+)")
-	return re.MatchString(s)
+	return dateTimeFormat.MatchString(s)
 }
 
 func round(val float64, roundOn float64, places int) (newVal float64) {
@@ -0,0 +1,179 @@
+package bic
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+)
+
+// CacheStats are the counters of a ResultCache since it was created.
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+}
+
+type cacheKey struct {
+	sum     [sha256.Size]byte
+	version uint64
+}
+
+type cacheEntry struct {
+	key   cacheKey
+	valid bool
+	err   error
+}
+
+// ResultCache is a bounded LRU of validation results, keyed by the SHA-256 of the raw
+// payload and the version of the compiled config that validated it.
+type ResultCache struct {
+	mutex    sync.Mutex
+	capacity int
+	entries  map[cacheKey]*list.Element
+	versions map[uint64]map[cacheKey]*list.Element //The entries of each config version
+	order    *list.List                            //Most recently used first
+	stats    CacheStats
+}
+
+func NewResultCache(capacity int) *ResultCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ResultCache{
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element, capacity),
+		versions: make(map[uint64]map[cacheKey]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func newCacheKey(payloadContent []byte, config *CompiledConfig) cacheKey {
+	return cacheKey{sum: sha256.Sum256(payloadContent), version: config.Version()}
+}
+
+func (cache *ResultCache) get(key cacheKey) (*cacheEntry, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		cache.stats.Misses++
+		return nil, false
+	}
+	cache.stats.Hits++
+	cache.order.MoveToFront(element)
+	return element.Value.(*cacheEntry), true
+}
+
+// add stores an entry unless current, called under the lock, reports that its config
+// version is no longer served: its results would never be invalidated.
+func (cache *ResultCache) add(entry *cacheEntry, current func(version uint64) bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if !current(entry.key.version) {
+		return
+	}
+	if element, ok := cache.entries[entry.key]; ok {
+		element.Value = entry
+		cache.order.MoveToFront(element)
+		return
+	}
+	element := cache.order.PushFront(entry)
+	cache.entries[entry.key] = element
+	if cache.versions[entry.key.version] == nil {
+		cache.versions[entry.key.version] = make(map[cacheKey]*list.Element)
+	}
+	cache.versions[entry.key.version][entry.key] = element
+	if cache.order.Len() > cache.capacity {
+		cache.remove(cache.order.Back())
+		cache.stats.Evictions++
+	}
+}
+
+func (cache *ResultCache) remove(element *list.Element) {
+	key := element.Value.(*cacheEntry).key
+	cache.order.Remove(element)
+	delete(cache.entries, key)
+	if delete(cache.versions[key.version], key); len(cache.versions[key.version]) == 0 {
+		delete(cache.versions, key.version)
+	}
+}
+
+// Invalidate drops every result computed with the given config version.
+func (cache *ResultCache) Invalidate(version uint64) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, element := range cache.versions[version] {
+		cache.remove(element)
+		cache.stats.Invalidations++
+	}
+}
+
+func (cache *ResultCache) Stats() CacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	stats := cache.stats
+	stats.Entries = cache.order.Len()
+	return stats
+}
+
+// CachedValidator validates payloads with the config the store serves for their producer
+// token, and returns the cached result when the same payload was already validated with
+// that config. A nil Cache disables caching.
+type CachedValidator struct {
+	Store  *ConfigStore
+	Cache  *ResultCache
+	Logger Logger
+}
+
+// NewCachedValidator returns a validator whose cache entries are dropped as soon as the
+// store swaps the config that produced them.
+func NewCachedValidator(store *ConfigStore, cache *ResultCache, log Logger) *CachedValidator {
+	if cache != nil {
+		store.OnSwap(func(token string, old *CompiledConfig) {
+			cache.Invalidate(old.Version())
+		})
+	}
+	return &CachedValidator{Store: store, Cache: cache, Logger: log}
+}
+
+func (validator *CachedValidator) logger() Logger {
+	if validator.Logger == nil {
+		return DefaultLogger
+	}
+	return validator.Logger
+}
+
+func (validator *CachedValidator) Validate(token string, payloadContent []byte) (bool, error) {
+	return validator.ValidateContext(context.Background(), token, payloadContent)
+}
+
+func (validator *CachedValidator) ValidateContext(ctx context.Context, token string, payloadContent []byte) (bool, error) {
+	config, ok := validator.Store.Get(token)
+	if !ok {
+		validator.logger().Error("Producer config not found", nil, F("token", redactToken(token)))
+		return false, apierrors.NewNotFoundApiError("producer config not found")
+	}
+	if validator.Cache == nil {
+		return validate(ctx, validator.logger(), payloadContent, config.Config)
+	}
+
+	key := newCacheKey(payloadContent, config)
+	if entry, hit := validator.Cache.get(key); hit {
+		return entry.valid, entry.err
+	}
+
+	valid, err := validate(ctx, validator.logger(), payloadContent, config.Config)
+	if ctx.Err() == nil { //Results cut short by the context depend on the request, not on the payload
+		validator.Cache.add(&cacheEntry{key: key, valid: valid, err: err}, func(version uint64) bool {
+			//A swap in the meantime has already invalidated the version
+			current, ok := validator.Store.Get(token)
+			return ok && current.Version() == version
+		})
+	}
+	return valid, err
+}
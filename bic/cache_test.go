@@ -0,0 +1,169 @@
+package bic
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+)
+
+func newCachedValidator(t *testing.T, capacity int) (*CachedValidator, *ConfigStore) {
+	store := NewConfigStore()
+	if err := store.Load("token", mandatoryConfig); err != nil {
+		t.Fatalf("Error loading config %v", err)
+	}
+	return NewCachedValidator(store, NewResultCache(capacity), NopLogger{}), store
+}
+
+func TestCachedValidatorHitsAndMisses(t *testing.T) {
+	validator, _ := newCachedValidator(t, 10)
+	valid := []byte(streamCorpus[1])
+	invalid := []byte(streamCorpus[2])
+
+	for i := 0; i < 3; i++ {
+		if ok, err := validator.Validate("token", valid); !ok {
+			t.Fatalf("expected valid payload, got %v", err)
+		}
+		if ok, err := validator.Validate("token", invalid); ok || err == nil {
+			t.Fatalf("expected invalid payload")
+		}
+	}
+
+	stats := validator.Cache.Stats()
+	if stats.Hits != 4 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	_, first := validator.Validate("token", invalid)
+	_, second := validator.Validate("token", invalid)
+	if first.Error() != second.Error() {
+		t.Errorf("expected the cached error, got %v and %v", first, second)
+	}
+
+	if _, err := validator.Validate("unknown", valid); err == nil {
+		t.Errorf("expected an error for an unknown token")
+	}
+}
+
+func TestResultCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	validator, _ := newCachedValidator(t, 2)
+	payloads := [][]byte{[]byte(streamCorpus[1]), []byte(streamCorpus[2]), []byte(streamCorpus[3])}
+
+	validator.Validate("token", payloads[0])
+	validator.Validate("token", payloads[1])
+	validator.Validate("token", payloads[0]) //payloads[1] becomes the least recently used
+	validator.Validate("token", payloads[2])
+
+	stats := validator.Cache.Stats()
+	if stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	validator.Validate("token", payloads[0])
+	if hits := validator.Cache.Stats().Hits; hits != stats.Hits+1 {
+		t.Errorf("expected payloads[0] to stay cached")
+	}
+	validator.Validate("token", payloads[1])
+	if misses := validator.Cache.Stats().Misses; misses != stats.Misses+1 {
+		t.Errorf("expected payloads[1] to be evicted")
+	}
+}
+
+func TestCachedValidatorIsInvalidatedOnSwap(t *testing.T) {
+	validator, store := newCachedValidator(t, 10)
+	numberDays := []byte(streamCorpus[1])
+	stringDays := []byte(streamCorpus[2])
+
+	validator.Validate("token", numberDays)
+	validator.Validate("token", stringDays)
+	before, _ := store.Get("token")
+
+	swapped := bytes.Replace(mandatoryConfig, []byte(`"estimated_days": "number", "flags"`), []byte(`"estimated_days": "string", "flags"`), 1)
+	if err := store.Load("token", swapped); err != nil {
+		t.Fatalf("Error loading config %v", err)
+	}
+	after, _ := store.Get("token")
+	if before.Version() == after.Version() {
+		t.Fatalf("expected a new config version")
+	}
+
+	stats := validator.Cache.Stats()
+	if stats.Entries != 0 || stats.Invalidations != 2 {
+		t.Errorf("expected the old results to be dropped, got %+v", stats)
+	}
+	if ok, _ := validator.Validate("token", numberDays); ok {
+		t.Errorf("expected the swapped config to reject numeric estimated_days")
+	}
+	if ok, err := validator.Validate("token", stringDays); !ok {
+		t.Errorf("expected the swapped config to accept string estimated_days, got %v", err)
+	}
+
+	store.Delete("token")
+	if _, err := validator.Validate("token", stringDays); err == nil {
+		t.Errorf("expected an error for a deleted token")
+	}
+	if entries := validator.Cache.Stats().Entries; entries != 0 {
+		t.Errorf("expected the deleted config results to be dropped, got %v entries", entries)
+	}
+}
+
+func TestCachedValidatorDoesNotCacheCanceledValidations(t *testing.T) {
+	validator, _ := newCachedValidator(t, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := validator.ValidateContext(ctx, "token", []byte(streamCorpus[1])); err == nil {
+		t.Fatalf("expected a context error")
+	}
+	if entries := validator.Cache.Stats().Entries; entries != 0 {
+		t.Errorf("expected no cached result, got %v entries", entries)
+	}
+	if ok, err := validator.Validate("token", []byte(streamCorpus[1])); !ok {
+		t.Errorf("expected valid payload, got %v", err)
+	}
+}
+
+func TestCachedValidatorConcurrentSwaps(t *testing.T) {
+	validator, store := newCachedValidator(t, 4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				validator.Validate("token", []byte(streamCorpus[(i+j)%len(streamCorpus)]))
+				if i == 0 && j%10 == 0 {
+					store.Load("token", mandatoryConfig)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := validator.Cache.Stats(); stats.Hits+stats.Misses != 8*50 || stats.Entries > 4 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// swappingLogger swaps the config of the token while a payload is being validated.
+type swappingLogger struct {
+	NopLogger
+	store *ConfigStore
+}
+
+func (log swappingLogger) Error(message string, err error, fields ...Field) {
+	log.store.Load("token", mandatoryConfig)
+}
+
+func TestCachedValidatorSkipsResultsOfSwappedConfigs(t *testing.T) {
+	validator, store := newCachedValidator(t, 10)
+	validator.Logger = swappingLogger{store: store}
+
+	if valid, _ := validator.Validate("token", []byte(streamCorpus[2])); valid {
+		t.Fatalf("expected an invalid payload")
+	}
+	if entries := validator.Cache.Stats().Entries; entries != 0 {
+		t.Errorf("expected the result of the swapped config to be skipped, got %v entries", entries)
+	}
+}
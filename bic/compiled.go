@@ -0,0 +1,136 @@
+package bic
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// compiledVersions numbers every compiled config, so results computed for one config are
+// never mistaken for results of another.
+var compiledVersions uint64
+
+// metricNode is one level of allowed_metrics. Objects have children, anything else is
+// a leaf whose type is the configured value.
+type metricNode struct {
+	isObject bool
+	children map[string]*metricNode
+	leafType string
+}
+
+type compiledEntity struct {
+	config    *EntityConfig
+	metrics   *metricNode
+	piiFields map[string]bool
+}
+
+// CompiledConfig is a producer configuration preprocessed for the streaming validator.
+type CompiledConfig struct {
+	Config          *StructProducerConfig
+	version         uint64
+	limits          Limits
+	entities        []compiledEntity
+	mandatoryLeaves bool
+}
+
+func Compile(config *StructProducerConfig) *CompiledConfig {
+	compiled := &CompiledConfig{
+		Config:  config,
+		version: atomic.AddUint64(&compiledVersions, 1),
+		limits:  DefaultLimits,
+	}
+	if config.Limits != nil {
+		compiled.limits = *config.Limits
+	}
+
+	entityConfigs := config.EntityConfigs()
+	for i := range entityConfigs {
+		entityConfig := &entityConfigs[i]
+		compiled.mandatoryLeaves = compiled.mandatoryLeaves || entityConfig.MandatoryFields != nil
+		compiled.entities = append(compiled.entities, compiledEntity{
+			config:    entityConfig,
+			metrics:   compileMetrics(entityConfig.AllowedMetrics),
+			piiFields: piiFieldSet(entityConfig.PIIFields),
+		})
+	}
+	return compiled
+}
+
+// Limits returns the limits enforced for the config, DefaultLimits when it declares none.
+func (config *CompiledConfig) Limits() Limits {
+	return config.limits
+}
+
+// Version identifies the compilation, every call to Compile returns a new version.
+func (config *CompiledConfig) Version() uint64 {
+	return config.version
+}
+
+func compileMetrics(metrics interface{}) *metricNode {
+	block, isMap := metrics.(map[string]interface{})
+	if !isMap {
+		return &metricNode{leafType: fmt.Sprintf("%v", metrics)}
+	}
+
+	node := &metricNode{isObject: true, children: make(map[string]*metricNode, len(block))}
+	for key, value := range block {
+		node.children[key] = compileMetrics(value)
+	}
+	return node
+}
+
+// findEntity mirrors StructProducerConfig.FindEntity over the compiled entities.
+func (compiled *CompiledConfig) findEntity(entity string) int {
+	for i, compiledEntity := range compiled.entities {
+		if compiled.Config.EntityMatch.Matches(compiledEntity.config.Entity, entity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkLeaf validates a metric leaf with the same rules as validatePathAndTypeOfLeafMetric.
+// On failure it returns the error and the path it points at.
+func (entity *compiledEntity) checkLeaf(log Logger, path []string, value interface{}) ([]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	node := entity.metrics
+	for position := 0; ; position++ {
+		if !node.isObject {
+			masked := isPIIPath(entity.piiFields, path)
+			if err := checkLeavesTypes(log, value, node.leafType, path[len(path)-1], masked); err != nil {
+				return path, err
+			}
+			return nil, nil
+		}
+
+		if len(node.children) == 0 {
+			err := fmt.Errorf("invalid metric name")
+			log.Error("invalid metric name", err)
+			if position >= len(path) {
+				return path, err
+			}
+			return path[:position+1], err
+		}
+
+		if position >= len(path) {
+			err := fmt.Errorf("invalid metric level")
+			log.Error("invalid metric level", err)
+			return path, err
+		}
+
+		child, found := node.children[path[position]]
+		if !found {
+			err := fmt.Errorf("invalid metric name")
+			log.Error("invalid metric name", err)
+			return path[:position+1], err
+		}
+		node = child
+	}
+}
+
+func metricPathError(path []string, err error) error {
+	return fmt.Errorf("%v at %v", err.Error(), strings.Join(path, "."))
+}
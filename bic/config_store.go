@@ -0,0 +1,82 @@
+package bic
+
+import (
+	"sync"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+)
+
+// SwapFunc is called after the config of a producer token has been replaced or deleted,
+// with the config that is no longer served.
+type SwapFunc func(token string, old *CompiledConfig)
+
+// ConfigStore keeps the compiled config of each producer token and allows replacing them
+// while payloads are being validated.
+type ConfigStore struct {
+	mutex     sync.RWMutex
+	configs   map[string]*CompiledConfig
+	listeners []SwapFunc
+}
+
+func NewConfigStore() *ConfigStore {
+	return &ConfigStore{configs: make(map[string]*CompiledConfig)}
+}
+
+func (store *ConfigStore) Get(token string) (*CompiledConfig, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	config, ok := store.configs[token]
+	return config, ok
+}
+
+// Load parses the config of a producer and swaps it in.
+func (store *ConfigStore) Load(token string, configBytes []byte) apierrors.ApiError {
+	config, err := GetProducerConfig(token, configBytes)
+	if err != nil {
+		return err
+	}
+	store.Swap(token, config)
+	return nil
+}
+
+// Swap compiles config and serves it for token from now on. It returns the compiled config,
+// whose version differs from the one it replaces.
+func (store *ConfigStore) Swap(token string, config *StructProducerConfig) *CompiledConfig {
+	compiled := Compile(config)
+
+	store.mutex.Lock()
+	old := store.configs[token]
+	store.configs[token] = compiled
+	listeners := store.listeners
+	store.mutex.Unlock()
+
+	if old != nil {
+		notify(listeners, token, old)
+	}
+	return compiled
+}
+
+func (store *ConfigStore) Delete(token string) {
+	store.mutex.Lock()
+	old, ok := store.configs[token]
+	delete(store.configs, token)
+	listeners := store.listeners
+	store.mutex.Unlock()
+
+	if ok {
+		notify(listeners, token, old)
+	}
+}
+
+// OnSwap registers fn to be called every time a config is replaced or deleted.
+func (store *ConfigStore) OnSwap(fn SwapFunc) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.listeners = append(store.listeners[:len(store.listeners):len(store.listeners)], fn)
+}
+
+func notify(listeners []SwapFunc, token string, old *CompiledConfig) {
+	for _, listener := range listeners {
+		listener(token, old)
+	}
+}
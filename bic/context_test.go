@@ -0,0 +1,87 @@
+package bic
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+)
+
+func TestValidateContext(t *testing.T) {
+	captureLogs(t)
+
+	config, _ := GetProducerConfig("1", []byte(piiConfigJS))
+	payload := []byte(`{"id": "1", "entity": "SHIPMENT", "metrics": {"buyer": {"card": 1, "doc": 2}}}`)
+
+	valid, err := ValidateContext(context.Background(), payload, config)
+	if !valid {
+		t.Fatalf("expected valid payload, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = ValidateContext(canceled, payload, config)
+	if apiError, ok := err.(apierrors.ApiError); !ok || apiError.Code() != "validation_canceled" {
+		t.Errorf("expected validation_canceled, got %v", err)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	_, err = ValidateContext(expired, payload, config)
+	if apiError, ok := err.(apierrors.ApiError); !ok || apiError.Code() != "validation_timeout" || apiError.Status() != http.StatusRequestTimeout {
+		t.Errorf("expected validation_timeout, got %v", err)
+	}
+}
+
+func TestValidateContextStopsWalkingMetrics(t *testing.T) {
+	log := captureLogs(t)
+
+	config, _ := GetProducerConfig("1", []byte(piiConfigJS))
+	payload, _ := GetPayloadBody("1", []byte(`{"id": "1", "entity": "SHIPMENT", "metrics": {"buyer": {"card": 1}}}`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := validatePayload(ctx, log, payload, config)
+	if err == nil || err.Code() != "validation_canceled" {
+		t.Errorf("expected validation_canceled while walking metrics, got %v", err)
+	}
+}
+
+// cancelAfter is a context reported done after a number of Err calls, to cancel a
+// validation while the payload is being decoded.
+type cancelAfter struct {
+	context.Context
+	calls int
+}
+
+func (ctx *cancelAfter) Err() error {
+	if ctx.calls--; ctx.calls < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestValidateContextStopsDecoding(t *testing.T) {
+	log := captureLogs(t)
+
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = "1"
+	}
+	payload := []byte(`{"id": "1", "entity": "SHIPMENT", "metrics": {"buyer": {"card": [` + strings.Join(items, ",") + `]}}}`)
+
+	_, err := getPayloadBody(&cancelAfter{Context: context.Background(), calls: 1}, log, "1", payload, DefaultLimits)
+	if err == nil || err.Code() != "validation_canceled" {
+		t.Errorf("expected validation_canceled while decoding, got %v", err)
+	}
+
+	config, _ := GetProducerConfig("1", []byte(piiConfigJS))
+	validator := NewStreamValidator(config, log)
+	_, streamError := validator.ValidateContext(&cancelAfter{Context: context.Background(), calls: 2}, payload)
+	if apiError, ok := streamError.(apierrors.ApiError); !ok || apiError.Code() != "validation_canceled" {
+		t.Errorf("expected validation_canceled while scanning, got %v", streamError)
+	}
+}
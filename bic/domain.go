@@ -4,6 +4,8 @@ type StructProducerConfig struct {
 	ID              string                 `json:"id"`
 	ProducerName    string                 `json:"producer_name"`
 	Entity          string                 `json:"entity"`
+	Entities        []EntityConfig         `json:"entities"`
+	EntityMatch     EntityMatchPolicy      `json:"entity_match"`
 	Status          string                 `json:"status"`
 	AllowGet        bool                   `json:"allow_get"`
 	SkipValidation  bool                   `json:"skip_validation"`
@@ -11,12 +13,21 @@ type StructProducerConfig struct {
 	AllowedMetrics  map[string]interface{} `json:"allowed_metrics"`
 	FlowConfig      FlowConfig             `json:"flow_config"`
 	MandatoryFields *[]string              `json:"mandatory_fields"`
+	PIIFields       *[]string              `json:"pii_fields"`
+	Limits          *Limits                `json:"limits"`
 	CreatedAt       string                 `json:"created_at"`
 	CreatedBy       string                 `json:"created_by"`
 	UpdatedAt       *string                `json:"updated_at"`
 	UpdatedBy       *string                `json:"updated_by"`
 }
 
+type EntityConfig struct {
+	Entity          string                 `json:"entity"`
+	AllowedMetrics  map[string]interface{} `json:"allowed_metrics"`
+	MandatoryFields *[]string              `json:"mandatory_fields"`
+	PIIFields       *[]string              `json:"pii_fields"`
+}
+
 type FlowConfig struct {
 	BigQueueTopic      string    `json:"big_queue_topic"`
 	Decorations        *[]string `json:"decorations"`
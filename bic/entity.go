@@ -0,0 +1,91 @@
+package bic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type EntityMatchPolicy string
+
+const (
+	EntityMatchExact           EntityMatchPolicy = "exact"
+	EntityMatchCaseInsensitive EntityMatchPolicy = "case_insensitive"
+)
+
+// Matches reports whether the payload entity matches the configured one. An empty
+// policy behaves as EntityMatchCaseInsensitive, which was the historical behavior.
+func (policy EntityMatchPolicy) Matches(configEntity string, payloadEntity string) bool {
+	if policy == EntityMatchExact {
+		return configEntity == payloadEntity
+	}
+	return strings.EqualFold(configEntity, payloadEntity)
+}
+
+// UnmarshalJSON rejects unknown policies, so a typo fails when the config is loaded
+// instead of silently matching case insensitively.
+func (policy *EntityMatchPolicy) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+	switch EntityMatchPolicy(*value) {
+	case "", EntityMatchExact, EntityMatchCaseInsensitive:
+		*policy = EntityMatchPolicy(*value)
+		return nil
+	}
+	return fmt.Errorf("unknown entity_match %q, expected %q or %q", *value, EntityMatchExact, EntityMatchCaseInsensitive)
+}
+
+// legacyEntity returns the configuration of the legacy top level entity.
+func (config *StructProducerConfig) legacyEntity() EntityConfig {
+	return EntityConfig{
+		Entity:          config.Entity,
+		AllowedMetrics:  config.AllowedMetrics,
+		MandatoryFields: config.MandatoryFields,
+		PIIFields:       config.PIIFields,
+	}
+}
+
+// EntityConfigs returns every entity configuration, starting with the legacy top level
+// entity when it is set.
+func (config *StructProducerConfig) EntityConfigs() []EntityConfig {
+	var entityConfigs []EntityConfig
+	if config.Entity != "" {
+		entityConfigs = append(entityConfigs, config.legacyEntity())
+	}
+	return append(entityConfigs, config.Entities...)
+}
+
+// FindEntity returns the entity configuration that applies to the given payload entity.
+// The legacy top level entity (with its allowed_metrics and mandatory_fields) is checked
+// before the entities list.
+func (config *StructProducerConfig) FindEntity(entity string) (*EntityConfig, bool) {
+	if config.Entity != "" && config.EntityMatch.Matches(config.Entity, entity) {
+		legacy := config.legacyEntity()
+		return &legacy, true
+	}
+
+	for i := range config.Entities {
+		if config.EntityMatch.Matches(config.Entities[i].Entity, entity) {
+			return &config.Entities[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// EntityNames returns every entity declared by the producer configuration.
+func (config *StructProducerConfig) EntityNames() []string {
+	var names []string
+	if config.Entity != "" {
+		names = append(names, config.Entity)
+	}
+	for _, entityConfig := range config.Entities {
+		names = append(names, entityConfig.Entity)
+	}
+	return names
+}
@@ -0,0 +1,91 @@
+package bic
+
+import (
+	"testing"
+)
+
+var multiEntityConfig = []byte(`{
+	"id": "2",
+	"producer_name": "shipping_metrics",
+	"status": "enabled",
+	"entity_match": "exact",
+	"entities": [
+		{
+			"entity": "SHIPMENT",
+			"allowed_metrics": {"handling_time": {"estimated_days": "number"}},
+			"mandatory_fields": ["handling_time.estimated_days"]
+		},
+		{
+			"entity": "ORDER",
+			"allowed_metrics": {"payment": {"status": "string"}}
+		}
+	]
+}`)
+
+func TestValidateMultipleEntities(t *testing.T) {
+	config, err := GetProducerConfig("1", multiEntityConfig)
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		valid   bool
+	}{
+		{"shipment", `{"id": "1", "entity": "SHIPMENT", "metrics": {"handling_time": {"estimated_days": 2}}}`, true},
+		{"order", `{"id": "1", "entity": "ORDER", "metrics": {"payment": {"status": "approved"}}}`, true},
+		{"order with shipment metrics", `{"id": "1", "entity": "ORDER", "metrics": {"handling_time": {"estimated_days": 2}}}`, false},
+		{"shipment missing mandatory field", `{"id": "1", "entity": "SHIPMENT", "metrics": {}}`, false},
+		{"exact policy rejects case", `{"id": "1", "entity": "order", "metrics": {}}`, false},
+		{"unknown entity", `{"id": "1", "entity": "PACK", "metrics": {}}`, false},
+	}
+
+	for _, test := range tests {
+		valid, _ := Validate([]byte(test.payload), config)
+		if valid != test.valid {
+			t.Errorf("%s: expected valid=%v, got %v", test.name, test.valid, valid)
+		}
+	}
+}
+
+func TestEntityMatchPolicy(t *testing.T) {
+	if !EntityMatchPolicy("").Matches("SHIPMENT", "shipment") {
+		t.Error("default policy should be case insensitive")
+	}
+	if !EntityMatchCaseInsensitive.Matches("SHIPMENT", "Shipment") {
+		t.Error("case insensitive policy should match")
+	}
+	if EntityMatchExact.Matches("SHIPMENT", "shipment") {
+		t.Error("exact policy should not match different case")
+	}
+}
+
+func TestUnknownEntityMatchPolicy(t *testing.T) {
+	captureLogs(t)
+	for _, match := range []string{`"exact"`, `"case_insensitive"`, `""`, `null`} {
+		if _, err := GetProducerConfig("1", []byte(`{"entity": "SHIPMENT", "entity_match": `+match+`}`)); err != nil {
+			t.Errorf("%v: unexpected error %v", match, err)
+		}
+	}
+	for _, match := range []string{`"Exact"`, `"insensitive"`, `1`} {
+		if _, err := GetProducerConfig("1", []byte(`{"entity": "SHIPMENT", "entity_match": `+match+`}`)); err == nil {
+			t.Errorf("%v: expected the config to be rejected", match)
+		}
+	}
+}
+
+func TestFindEntityLegacyConfig(t *testing.T) {
+	config := &StructProducerConfig{
+		Entity:         "SHIPMENT_TEST",
+		AllowedMetrics: map[string]interface{}{"lead_time": map[string]interface{}{"estimated_days": "number"}},
+	}
+
+	entityConfig, found := config.FindEntity("shipment_test")
+	if !found {
+		t.Fatal("legacy entity not found")
+	}
+	if entityConfig.AllowedMetrics["lead_time"] == nil {
+		t.Error("legacy allowed metrics not propagated")
+	}
+}
@@ -0,0 +1,723 @@
+// Package gen generates specialized Go validators from bic producer configurations.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+// Generate returns the source of a Go file that validates payloads for config with the
+// same semantics and errors as bic.Validate, in a single pass over the tokens that fills
+// typed structs. The payloads the pass can't settle, like the ones with repeated keys or
+// with unknown metrics, are validated by bic.Validate with the configuration embedded in
+// the file. Mandatory fields are matched on the full path of each leaf, flattenMetricsMap
+// loses it below the second level after a nested object. source is only used in the
+// header comment.
+func Generate(config *bic.StructProducerConfig, packageName string, source string) ([]byte, error) {
+	g := &generator{names: make(map[string]bool)}
+	entityConfigs := config.EntityConfigs()
+	if len(entityConfigs) == 0 {
+		return nil, fmt.Errorf("producer configuration %v does not declare any entity", config.ID)
+	}
+
+	limits := bic.DefaultLimits
+	if config.Limits != nil {
+		limits = *config.Limits
+	}
+	configSource, err := validationConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	g.printf("// Code generated by bicgen from %v. DO NOT EDIT.\n\n", source)
+	g.printf("package %v\n\n", packageName)
+	g.printf("import (\n\"encoding/json\"\n\"errors\"\n\"fmt\"\n\"strings\"\n\"sync\"\n\n")
+	g.printf("\"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors\"\n")
+	g.printf("\"github.com/mercadolibre/jsonschema_test/bic\"\n)\n\n")
+	g.printf("var limits = bic.Limits{MaxBytes: %d, MaxDepth: %d, MaxKeys: %d, MaxArrayLength: %d, MaxStringLength: %d}\n\n",
+		limits.MaxBytes, limits.MaxDepth, limits.MaxKeys, limits.MaxArrayLength, limits.MaxStringLength)
+	g.printf("// configSource holds the fields of the producer configuration bic.Validate needs.\n")
+	g.printf("const configSource = %v\n\n", configSource)
+
+	entities := make([]entity, len(entityConfigs))
+	for i, entityConfig := range entityConfigs {
+		name := g.uniqueName(identifier(entityConfig.Entity))
+		entities[i] = entity{EntityConfig: entityConfig, index: i, name: name, typed: "typed" + name}
+		if entityConfig.MandatoryFields != nil {
+			entities[i].mandatory = *entityConfig.MandatoryFields
+		}
+		entities[i].pii = make(map[string]bool)
+		if entityConfig.PIIFields != nil {
+			for _, field := range *entityConfig.PIIFields {
+				entities[i].pii[strings.ToLower(field)] = true
+			}
+		}
+	}
+
+	for i := range entities {
+		entities[i].metricsType = g.uniqueName(entities[i].name + "Metrics")
+		entities[i].function = g.uniqueName("scan" + entities[i].name + "Metrics")
+	}
+
+	g.generateValidate(config, entities)
+	g.generateScan(config, entities)
+	for i := range entities {
+		g.generateEntity(&entities[i])
+	}
+	g.printf("%v", runtimeSource)
+
+	formatted, err := format.Source(g.buffer.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return formatted, nil
+}
+
+// validationConfig returns the Go literal of the configuration fields Validate depends on.
+func validationConfig(config *bic.StructProducerConfig) (string, error) {
+	fields := map[string]interface{}{"id": config.ID, "status": config.Status}
+	if config.AllowedMetrics != nil {
+		fields["allowed_metrics"] = config.AllowedMetrics
+	}
+	if config.Entity != "" {
+		fields["entity"] = config.Entity
+	}
+	if len(config.Entities) > 0 {
+		fields["entities"] = config.Entities
+	}
+	if config.EntityMatch != "" {
+		fields["entity_match"] = config.EntityMatch
+	}
+	if config.MandatoryFields != nil {
+		fields["mandatory_fields"] = config.MandatoryFields
+	}
+	if config.PIIFields != nil {
+		fields["pii_fields"] = config.PIIFields
+	}
+	if config.Limits != nil {
+		fields["limits"] = config.Limits
+	}
+	content, err := json.MarshalIndent(fields, "", "\t")
+	if err != nil {
+		return "", fmt.Errorf("encoding the producer configuration: %v", err)
+	}
+	if bytes.ContainsRune(content, '`') {
+		return strconv.Quote(string(content)), nil
+	}
+	return "`" + string(content) + "`", nil
+}
+
+// entity is an entity configuration with the names of its generated code.
+type entity struct {
+	bic.EntityConfig
+	index       int
+	name        string
+	metricsType string
+	function    string
+	typed       string
+	mandatory   []string
+	pii         map[string]bool
+}
+
+// mandatoryMatches returns the indexes of the mandatory fields a non null leaf at path
+// satisfies.
+func (e *entity) mandatoryMatches(path string) []int {
+	var matches []int
+	for i, field := range e.mandatory {
+		if strings.EqualFold(field, path) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// mandatoryBelow reports whether a mandatory field goes below path.
+func (e *entity) mandatoryBelow(path string) bool {
+	for _, field := range e.mandatory {
+		if len(field) > len(path) && field[len(path)] == '.' && strings.EqualFold(field[:len(path)], path) {
+			return true
+		}
+	}
+	return false
+}
+
+type generator struct {
+	buffer bytes.Buffer
+	names  map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buffer, format, args...)
+}
+
+func (g *generator) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.names[unique] = true
+	return unique
+}
+
+func entityCondition(config *bic.StructProducerConfig, expression string, entity string) string {
+	if config.EntityMatch == bic.EntityMatchExact {
+		return fmt.Sprintf("%v == %q", expression, entity)
+	}
+	return fmt.Sprintf("strings.EqualFold(%v, %q)", expression, entity)
+}
+
+func (g *generator) generateValidate(config *bic.StructProducerConfig, entities []entity) {
+	g.printf("// Validate has the same semantics and errors as bic.Validate with the producer\n")
+	g.printf("// configuration this file was generated from.\n")
+	g.printf("func Validate(payloadContent []byte) (bool, error) {\n")
+	g.printf("if _, err := decode(payloadContent); err != nil {\nreturn false, err\n}\n")
+	g.printf("return true, nil\n}\n\n")
+
+	g.printf("// Decode validates the payload and, only when it is valid, returns the typed payload\n")
+	g.printf("// of its entity, filled in the same pass.\n")
+	g.printf("func Decode(payloadContent []byte) (interface{}, error) {\n")
+	g.printf("return decode(payloadContent)\n}\n\n")
+
+	g.printf("func decode(payloadContent []byte) (interface{}, error) {\n")
+	g.printf("scan := &payloadScan{decoder: bic.NewPayloadDecoder(payloadContent, limits), entityIndex: -1}\n")
+	g.printf("err := scan.document()\n")
+	if len(entities) > 1 {
+		g.printf("if err == nil && scan.retry && scan.entityIndex >= 0 {\n")
+		g.printf("// The metrics came before the entity, they are read again now that it is known\n")
+		g.printf("scan = &payloadScan{decoder: bic.NewPayloadDecoder(payloadContent, limits), entityIndex: scan.entityIndex}\n")
+		g.printf("err = scan.document()\n}\n")
+	}
+	g.printf("if err != nil || scan.fallback {\n")
+	g.printf("// bic.Validate settles the payloads the pass can't, so they get its errors\n")
+	g.printf("if valid, validationError := bic.Validate(payloadContent, producerConfig()); !valid {\nreturn nil, validationError\n}\n")
+	g.printf("if err != nil {\nreturn nil, err\n}\n")
+	g.printf("return scan.payload(), nil\n}\n\n")
+
+	g.printf("if _, err := bic.ValidatePayloadBody(\"1\", scan.envelope()); err != nil {\nreturn nil, err\n}\n")
+	g.printf("if scan.entityIndex < 0 {\n")
+	g.printf("return nil, apierrors.NewUnauthorizedApiError(\"provided entity does not match the one in the producer configuration\")\n}\n")
+	if !strings.EqualFold(config.Status, "enabled") {
+		g.printf("return nil, apierrors.NewUnauthorizedApiError(\"producer not enabled\")\n}\n\n")
+		return
+	}
+
+	mandatory := false
+	for _, e := range entities {
+		mandatory = mandatory || len(e.mandatory) > 0
+	}
+	if mandatory {
+		g.printf("switch scan.entityIndex {\n")
+		for _, e := range entities {
+			if len(e.mandatory) == 0 {
+				continue
+			}
+			g.printf("case %d:\n", e.index)
+			for i, field := range e.mandatory {
+				g.printf("if !scan.mandatory[%d] {\n", i)
+				g.printf("return nil, bic.NewNotAcceptableApiError(\"missing a few mandatory fields\", fmt.Errorf(\"missing mandatory field: %%v\", %q))\n}\n", field)
+			}
+		}
+		g.printf("}\n")
+	}
+	g.printf("if scan.err != nil {\n")
+	g.printf("return nil, bic.NewNotAcceptableApiError(\"provided metrics do not match the ones in the producer configuration\", scan.err)\n}\n")
+	g.printf("return scan.payload(), nil\n}\n\n")
+}
+
+// generateScan declares the state of the pass and the code that depends on the entity.
+func (g *generator) generateScan(config *bic.StructProducerConfig, entities []entity) {
+	mandatory := 0
+	for _, e := range entities {
+		if len(e.mandatory) > mandatory {
+			mandatory = len(e.mandatory)
+		}
+	}
+
+	g.printf("// payloadScan holds what one pass over the tokens of a payload collects. The payloads\n")
+	g.printf("// it can't settle set fallback.\n")
+	g.printf("type payloadScan struct {\n")
+	g.printf("decoder *bic.PayloadDecoder\nid, entity string\nentityIndex int\nmetricsPresent bool\nretry bool\nfallback bool\nerr error\n")
+	if mandatory > 0 {
+		g.printf("mandatory [%d]bool\n", mandatory)
+	}
+	for _, e := range entities {
+		g.printf("%v %v\n", e.typed, e.metricsType)
+	}
+	g.printf("}\n\n")
+
+	g.printf("// matchEntity returns the index of the entity configuration that applies to entity,\n")
+	g.printf("// -1 when none does.\n")
+	g.printf("func matchEntity(entity string) int {\nswitch {\n")
+	for _, e := range entities {
+		g.printf("case %v:\nreturn %d\n", entityCondition(config, "entity", e.Entity), e.index)
+	}
+	g.printf("}\nreturn -1\n}\n\n")
+
+	g.printf("// metrics reads the metrics object that value opens into the typed struct of the entity.\n")
+	g.printf("func (scan *payloadScan) metrics(value json.Token) error {\n")
+	if len(entities) == 1 {
+		g.printf("return scan.%v(&scan.%v)\n}\n\n", entities[0].function, entities[0].typed)
+	} else {
+		g.printf("switch scan.entityIndex {\n")
+		for _, e := range entities {
+			g.printf("case %d:\nreturn scan.%v(&scan.%v)\n", e.index, e.function, e.typed)
+		}
+		g.printf("}\n// The entity is not known yet, the metrics are read again once it is\n")
+		g.printf("scan.retry = true\nreturn scan.decoder.Skip(value)\n}\n\n")
+	}
+
+	g.printf("// payload returns the typed payload of the entity.\n")
+	g.printf("func (scan *payloadScan) payload() interface{} {\nswitch scan.entityIndex {\n")
+	for _, e := range entities {
+		g.printf("case %d:\nreturn &%vPayload{Entity: scan.entity, ID: scan.id, Metrics: scan.%v}\n", e.index, e.name, e.typed)
+	}
+	g.printf("}\nreturn nil\n}\n\n")
+}
+
+func (g *generator) generateEntity(e *entity) {
+	g.printf("type %vPayload struct {\n", e.name)
+	g.printf("Entity string `json:\"entity\"`\nID string `json:\"id\"`\nMetrics %v `json:\"metrics\"`\n}\n\n", e.metricsType)
+
+	var piiFields []string
+	if e.PIIFields != nil {
+		for _, field := range *e.PIIFields {
+			piiFields = append(piiFields, strconv.Quote(strings.ToLower(field)))
+		}
+	}
+	g.printf("var pii%v = map[string]bool{", e.name)
+	for _, field := range piiFields {
+		g.printf("%v: true,", field)
+	}
+	g.printf("}\n\n")
+	if len(e.mandatory) > 0 {
+		g.printf("var mandatory%v = []string{", e.name)
+		for _, field := range e.mandatory {
+			g.printf("%q,", field)
+		}
+		g.printf("}\n\n")
+	}
+
+	g.generateBlock(e, e.metricsType, e.function, e.AllowedMetrics, nil)
+}
+
+// blockField is a configured key of an allowed_metrics object.
+type blockField struct {
+	key    string
+	name   string
+	config interface{}
+	// typeName and function are set for objects
+	typeName string
+	function string
+}
+
+// generateBlock declares the typed struct of an allowed_metrics object and the method
+// reading it, then the ones of the objects it holds.
+func (g *generator) generateBlock(e *entity, typeName string, function string, metrics map[string]interface{}, path []string) {
+	fieldNames := make(map[string]bool)
+	var fields []blockField
+	for _, key := range sortedKeys(metrics) {
+		field := blockField{key: key, name: identifier(key), config: metrics[key]}
+		for i := 2; fieldNames[field.name]; i++ {
+			field.name = identifier(key) + strconv.Itoa(i)
+		}
+		fieldNames[field.name] = true
+		if _, isMap := field.config.(map[string]interface{}); isMap {
+			childPath := append(append([]string{}, path...), key)
+			field.typeName = g.uniqueName(e.name + pathIdentifier(childPath))
+			field.function = g.uniqueName("scan" + e.name + pathIdentifier(childPath))
+		}
+		fields = append(fields, field)
+	}
+
+	g.printf("type %v struct {\n", typeName)
+	for _, field := range fields {
+		if field.typeName != "" {
+			g.printf("%v *%v `json:%q`\n", field.name, field.typeName, field.key+",omitempty")
+			continue
+		}
+		g.printf("%v %v `json:%q`\n", field.name, goType(fmt.Sprintf("%v", field.config)), field.key+",omitempty")
+	}
+	g.printf("}\n\n")
+
+	root := len(path) == 0
+	if root {
+		g.printf("// %v reads the metrics of %v.\n", function, e.Entity)
+		g.printf("// Top level leaves are never validated, like in the interpreter.\n")
+	}
+	g.printf("func (scan *payloadScan) %v(block *%v) error {\n", function, typeName)
+	if len(fields) > 0 {
+		g.printf("var seen [%d]bool\n", len(fields))
+	}
+	g.printf("for {\nkey, value, end, err := scan.key()\nif end || err != nil {\nreturn err\n}\n")
+	g.printf("switch key {\n")
+	for i, field := range fields {
+		g.generateCase(e, field, i, append(append([]string{}, path...), field.key))
+	}
+	g.printf("default:\n")
+	if root {
+		g.printf("if value == json.Delim('{') {\nerr = scan.unknown(value)\n} else {\n")
+		if len(e.mandatory) > 0 {
+			g.printf("scan.mandatoryLeaf(key, value, mandatory%v)\n", e.name)
+		}
+		g.printf("err = scan.decoder.Skip(value)\n}\n")
+	} else {
+		g.printf("err = scan.unknown(value)\n")
+	}
+	g.printf("}\nif err != nil {\nreturn err\n}\n}\n}\n\n")
+
+	for _, field := range fields {
+		if field.typeName != "" {
+			g.generateBlock(e, field.typeName, field.function, field.config.(map[string]interface{}), append(append([]string{}, path...), field.key))
+		}
+	}
+}
+
+// generateCase writes the switch case of one configured key.
+func (g *generator) generateCase(e *entity, field blockField, index int, path []string) {
+	dottedPath := strings.Join(path, ".")
+	root := len(path) == 1
+	g.printf("case %q:\n", field.key)
+	g.printf("scan.repeated(&seen[%d])\n", index)
+	g.printf("switch {\n")
+
+	if field.typeName != "" {
+		block := field.config.(map[string]interface{})
+		g.printf("case value == json.Delim('{'):\n")
+		g.printf("block.%v = new(%v)\nerr = scan.%v(block.%v)\n", field.name, field.typeName, field.function, field.name)
+		g.printf("case value != nil:\n")
+		g.generateMandatory(e, dottedPath)
+		if root {
+			g.printf("err = scan.decoder.Skip(value)\n")
+		} else {
+			levelError := "invalid metric level"
+			if len(block) == 0 {
+				levelError = "invalid metric name"
+			}
+			g.printf("err = scan.invalidLevel(value, %q)\n", levelError+" at "+dottedPath)
+		}
+		g.printf("}\n")
+		return
+	}
+
+	leafType := fmt.Sprintf("%v", field.config)
+	g.printf("case value == json.Delim('{'):\n")
+	if e.mandatoryBelow(dottedPath) {
+		g.printf("// The leaves below can be mandatory fields\nscan.fallback = true\n")
+	}
+	g.printf("err = scan.nestedLeaves(value, %q, %q, %q, pii%v)\n", leafType, dottedPath, field.key, e.name)
+	g.printf("case value != nil:\n")
+	g.generateMandatory(e, dottedPath)
+
+	mismatch := fmt.Sprintf("err = scan.leafError(value, %q, %q, %v)\n", field.key, dottedPath, e.pii[strings.ToLower(dottedPath)])
+	if root {
+		mismatch = "err = scan.decoder.Skip(value)\n"
+	}
+	switch condition := leafCondition(leafType); {
+	case leafType == "array":
+		g.printf("if value == json.Delim('[') {\nvar array interface{}\narray, err = scan.decoder.Value(value)\n")
+		g.printf("block.%v, _ = array.([]interface{})\n} else {\n%v}\n", field.name, mismatch)
+	case condition == "":
+		g.printf("%v", mismatch)
+	default:
+		g.printf("if %v {\nblock.%v = &typed\n} else {\n%v}\n", condition, field.name, mismatch)
+	}
+	g.printf("}\n")
+}
+
+// generateMandatory marks the mandatory fields a non null leaf at path satisfies.
+func (g *generator) generateMandatory(e *entity, path string) {
+	for _, i := range e.mandatoryMatches(path) {
+		g.printf("scan.mandatory[%d] = true\n", i)
+	}
+}
+
+// leafCondition holds when value has the leaf type, binding it to typed. It is empty for
+// the unknown types, which no value has.
+func leafCondition(leafType string) string {
+	switch leafType {
+	case "number":
+		return "typed, ok := value.(float64); ok"
+	case "boolean_number":
+		return "typed, ok := value.(float64); ok && (typed == 0 || typed == 1)"
+	case "string":
+		return "typed, ok := value.(string); ok"
+	case "date", "time", "datetime":
+		return fmt.Sprintf("typed, ok := value.(string); ok && bic.IsLeafType(typed, %q)", leafType)
+	case "bool", "boolean":
+		return "typed, ok := value.(bool); ok"
+	}
+	return ""
+}
+
+func goType(leafType string) string {
+	switch leafType {
+	case "number", "boolean_number":
+		return "*float64"
+	case "string", "date", "time", "datetime":
+		return "*string"
+	case "bool", "boolean":
+		return "*bool"
+	case "array":
+		return "[]interface{}"
+	}
+	return "interface{}"
+}
+
+func sortedKeys(metrics map[string]interface{}) []string {
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func pathIdentifier(path []string) string {
+	var builder strings.Builder
+	for _, key := range path {
+		builder.WriteString(identifier(key))
+	}
+	return builder.String()
+}
+
+// identifier turns keys like "handling_time" or "SHIPMENT_TEST" into exported Go names.
+func identifier(key string) string {
+	var builder strings.Builder
+	upper := true
+	for _, r := range strings.ToLower(key) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		builder.WriteRune(r)
+	}
+	name := builder.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "M" + name
+	}
+	return name
+}
+
+// runtimeSource holds the helpers shared by every generated validator.
+const runtimeSource = `var interpreter struct {
+	once   sync.Once
+	config *bic.StructProducerConfig
+}
+
+// producerConfig returns the configuration bic.Validate settles the payloads the pass
+// can't with, so they get its exact errors.
+func producerConfig() *bic.StructProducerConfig {
+	interpreter.once.Do(func() {
+		config, err := bic.GetProducerConfig("1", []byte(configSource))
+		if err != nil {
+			panic(err)
+		}
+		interpreter.config = config
+	})
+	return interpreter.config
+}
+
+// presentMetrics stands for the metrics read by the pass, ValidatePayloadBody only checks
+// that they are not null.
+var presentMetrics = map[string]interface{}{}
+
+func (scan *payloadScan) envelope() *bic.StructPayload {
+	payload := &bic.StructPayload{ID: scan.id, Entity: scan.entity}
+	if scan.metricsPresent {
+		payload.Metrics = presentMetrics
+	}
+	return payload
+}
+
+// document reads the envelope like json.Unmarshal into a bic.StructPayload, and hands the
+// metrics to the entity.
+func (scan *payloadScan) document() error {
+	token, err := scan.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		scan.fallback = true
+		if err := scan.decoder.Skip(token); err != nil {
+			return err
+		}
+		return scan.decoder.End()
+	}
+
+	var seen [4]bool
+	for {
+		key, value, end, err := scan.key()
+		if err != nil {
+			return err
+		}
+		if end {
+			return scan.decoder.End()
+		}
+		switch {
+		case strings.EqualFold(key, "id"):
+			scan.repeated(&seen[0])
+			err = scan.envelopeString(value, &scan.id)
+		case strings.EqualFold(key, "entity"):
+			scan.repeated(&seen[1])
+			err = scan.envelopeString(value, &scan.entity)
+			scan.entityIndex = matchEntity(scan.entity)
+		case strings.EqualFold(key, "ProducerToken"):
+			var producerToken string
+			scan.repeated(&seen[2])
+			err = scan.envelopeString(value, &producerToken)
+		case strings.EqualFold(key, "metrics"):
+			scan.repeated(&seen[3])
+			switch value {
+			case nil:
+				scan.metricsPresent = false
+			case json.Delim('{'):
+				scan.metricsPresent = true
+				err = scan.metrics(value)
+			default:
+				scan.fallback = true
+				err = scan.decoder.Skip(value)
+			}
+		default:
+			err = scan.decoder.Skip(value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// envelopeString reads a string field of the envelope, null keeps its value.
+func (scan *payloadScan) envelopeString(value json.Token, target *string) error {
+	switch typed := value.(type) {
+	case string:
+		*target = typed
+	case nil:
+	default:
+		scan.fallback = true
+	}
+	return scan.decoder.Skip(value)
+}
+
+// key reads the next key of the current object and the token that starts its value, end
+// is set once the object is closed.
+func (scan *payloadScan) key() (key string, value json.Token, end bool, err error) {
+	token, err := scan.decoder.Token()
+	if err != nil || token == json.Delim('}') {
+		return "", nil, err == nil, err
+	}
+	value, err = scan.decoder.Token()
+	return token.(string), value, false, err
+}
+
+// repeated marks a key as seen. json.Unmarshal keeps the last value of repeated keys, the
+// interpreter settles them.
+func (scan *payloadScan) repeated(seen *bool) {
+	if *seen {
+		scan.fallback = true
+	}
+	*seen = true
+}
+
+// metricError keeps the first metric error, the interpreter returns any of them.
+func (scan *payloadScan) metricError(err error) {
+	if scan.err == nil {
+		scan.err = err
+	}
+}
+
+// leafError reports a leaf with the wrong type, reading the rest of its value.
+func (scan *payloadScan) leafError(token json.Token, key string, path string, masked bool) error {
+	value, err := scan.decoder.Value(token)
+	if err != nil {
+		return err
+	}
+	if masked {
+		value = "***"
+	}
+	scan.metricError(fmt.Errorf("field '%v' with different data type, sent value: %v at %v", key, value, path))
+	return nil
+}
+
+// invalidLevel reports a leaf sent for an object of the configuration.
+func (scan *payloadScan) invalidLevel(token json.Token, message string) error {
+	scan.metricError(errors.New(message))
+	return scan.decoder.Skip(token)
+}
+
+// nestedLeaves checks the object sent for a leaf, see checkNestedLeaves.
+func (scan *payloadScan) nestedLeaves(token json.Token, leafType string, path string, key string, piiFields map[string]bool) error {
+	value, err := scan.decoder.Value(token)
+	if err != nil {
+		return err
+	}
+	if err := checkNestedLeaves(value, leafType, path, key, piiFields); err != nil {
+		scan.metricError(err)
+	}
+	return nil
+}
+
+// unknown reads a value below a key missing from the configuration. Only null leaves are
+// accepted there, the interpreter reports the others.
+func (scan *payloadScan) unknown(value json.Token) error {
+	if value != json.Delim('{') {
+		if value != nil {
+			scan.fallback = true
+		}
+		return scan.decoder.Skip(value)
+	}
+	for {
+		_, next, end, err := scan.key()
+		if end || err != nil {
+			return err
+		}
+		if err := scan.unknown(next); err != nil {
+			return err
+		}
+	}
+}
+
+// mandatoryLeaf leaves to the interpreter the top level leaves with an unknown key that
+// satisfy a mandatory field, they differ from the configured key in case.
+func (scan *payloadScan) mandatoryLeaf(key string, value json.Token, mandatory []string) {
+	if value == nil {
+		return
+	}
+	for _, field := range mandatory {
+		if strings.EqualFold(field, key) {
+			scan.fallback = true
+		}
+	}
+}
+
+// checkNestedLeaves reports a leaf with the wrong type. Objects below a leaf are walked
+// and their leaves checked against the same type, like in the interpreter.
+func checkNestedLeaves(value interface{}, leafType string, path string, key string, piiFields map[string]bool) error {
+	if block, isMap := value.(map[string]interface{}); isMap {
+		for subKey, subValue := range block {
+			if err := checkNestedLeaves(subValue, leafType, path+"."+subKey, subKey, piiFields); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if value == nil || bic.IsLeafType(value, leafType) {
+		return nil
+	}
+	if piiFields[strings.ToLower(path)] {
+		value = "***"
+	}
+	return fmt.Errorf("field '%v' with different data type, sent value: %v at %v", key, value, path)
+}
+`
@@ -0,0 +1,80 @@
+package gen
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+func TestGenerateMatchesCommittedValidator(t *testing.T) {
+	generated := map[string]string{
+		"shipmenttest": "../config-productor.json",
+		"entitiestest": "../generated/entitiestest/testdata/config.json",
+	}
+	for packageName, configPath := range generated {
+		config, err := bic.GetProducerConfigFromFile(configPath)
+		if err != nil {
+			t.Fatalf("Error reading config %v", err)
+		}
+
+		source, err := Generate(config, packageName, filepath.Base(configPath))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		committed, err := ioutil.ReadFile("../generated/" + packageName + "/validator.go")
+		if err != nil {
+			t.Fatalf("Error reading generated file %v", err)
+		}
+		if !bytes.Equal(source, committed) {
+			t.Errorf("bic/generated/%v is stale, run go generate ./bic/generated/...", packageName)
+		}
+	}
+}
+
+func TestGenerateMultipleEntities(t *testing.T) {
+	config, configError := bic.GetProducerConfig("1", []byte(`{
+		"status": "disabled",
+		"entity_match": "exact",
+		"entities": [
+			{"entity": "SHIPMENT", "allowed_metrics": {"handling_time": {"estimated_days": "number"}, "3pl": {}}, "mandatory_fields": ["handling_time.estimated_days"]},
+			{"entity": "ORDER", "allowed_metrics": {"payment": {"status": "string", "card": "string"}}, "pii_fields": ["payment.card"]}
+		]
+	}`))
+	if configError != nil {
+		t.Fatalf("Error reading config %v", configError)
+	}
+
+	source, err := Generate(config, "multi", "multi.json")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "multi.go", source, 0); err != nil {
+		t.Errorf("generated code does not parse: %v", err)
+	}
+	for _, expected := range []string{"type ShipmentPayload struct", "type OrderPayload struct", `entity == "ORDER"`, `"payment.card": true`, "producer not enabled", "M3pl"} {
+		if !bytes.Contains(source, []byte(expected)) {
+			t.Errorf("expected %q in generated code", expected)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"handling_time": "HandlingTime",
+		"SHIPMENT_TEST": "ShipmentTest",
+		"3pl":           "M3pl",
+		"a-b.c":         "ABC",
+		"":              "M",
+	}
+	for key, expected := range tests {
+		if name := identifier(key); name != expected {
+			t.Errorf("identifier(%q) = %q, expected %q", key, name, expected)
+		}
+	}
+}
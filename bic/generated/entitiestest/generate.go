@@ -0,0 +1,6 @@
+// Package entitiestest is the validator generated by bicgen for a configuration with
+// several entities, mandatory fields and PII fields. It is kept in the tree to prove that
+// generated code and bic.Validate agree.
+package entitiestest
+
+//go:generate go run ../../../cmd/bicgen -config testdata/config.json -package entitiestest -out validator.go
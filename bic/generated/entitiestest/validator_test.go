@@ -0,0 +1,96 @@
+package entitiestest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+func describe(err error) string {
+	if apiError, ok := err.(apierrors.ApiError); ok {
+		return fmt.Sprintf("%v %v %v %v", apiError.Status(), apiError.Code(), apiError.Message(), apiError.Cause())
+	}
+	return fmt.Sprint(err)
+}
+
+func TestGeneratedValidatorMatchesInterpreter(t *testing.T) {
+	previousLogger := bic.DefaultLogger
+	bic.DefaultLogger = bic.NopLogger{}
+	defer func() { bic.DefaultLogger = previousLogger }()
+
+	config, err := bic.GetProducerConfigFromFile("testdata/config.json")
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+
+	payloads := []string{
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "shipment", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": null, "handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": "one", "handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"COUNT": 1, "handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "HANDLING_TIME": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"ESTIMATED_DAYS": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": "2"}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": {"a": 1}}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "estimated_days": null}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": {"a": 1, "b": null}, "handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "flag": 1, "when": "2020-06-04", "at": "12:30:00", "tags": [1, "a"], "active": true, "kind": null, "window": {"from": "2019-10-11T13:38:29-03:00"}}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "flag": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "when": "soon"}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "at": "25:00:00"}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "tags": {"a": [1]}}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "tags": "a"}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "active": 1}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "kind": "x"}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "window": 1}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "window": {"from": "now"}}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2}, "3pl": {"a": null}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2}, "3pl": {"a": 1}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2}, "3pl": 1}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": 5}}`,
+		`{"metrics": {"count": 1, "handling_time": {"estimated_days": 2}}, "id": "1", "entity": "SHIPMENT"}`,
+		`{"metrics": {"count": 1, "handling_time": {"estimated_days": "2"}}, "id": "1", "entity": "SHIPMENT"}`,
+		`{"metrics": {"payment": {"status": "paid"}}, "id": "1", "entity": "SHIPMENT"}`,
+		`{"metrics": {"payment": {"status": "paid"}}, "id": "1", "entity": "INVOICE"}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"status": "paid", "card": "4111"}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"status": "paid", "card": 4111}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"status": "paid", "card": {"number": 4111}}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"card": "4111"}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"Status": "paid"}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"count": 1, "payment": {"status": "paid"}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"status": "paid", "x": {"y": {"z": {"w": 1}}}}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"status": "paid", "x": {"y": {"z": {"w": {"v": 1}}}}}}}`,
+	}
+	for _, payload := range payloads {
+		expectedValid, expectedError := bic.Validate([]byte(payload), config)
+		valid, err := Validate([]byte(payload))
+
+		if valid != expectedValid || describe(err) != describe(expectedError) {
+			t.Errorf("%s: interpreter returned %v (%v), generated returned %v (%v)", payload, expectedValid, describe(expectedError), valid, describe(err))
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	typed, err := Decode([]byte(`{"metrics": {"payment": {"status": "paid", "card": "4111"}}, "id": "1", "entity": "ORDER"}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	payload := typed.(*OrderPayload)
+	if payload.ID != "1" || payload.Metrics.Payment == nil || *payload.Metrics.Payment.Status != "paid" || *payload.Metrics.Payment.Card != "4111" {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	typed, err = Decode([]byte(`{"entity": "SHIPMENT", "id": "1", "metrics": {"count": 1, "handling_time": {"estimated_days": 2, "flag": 0, "tags": [1], "active": false}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	handlingTime := typed.(*ShipmentPayload).Metrics.HandlingTime
+	if *typed.(*ShipmentPayload).Metrics.Count != 1 || *handlingTime.Flag != 0 || len(handlingTime.Tags) != 1 || *handlingTime.Active {
+		t.Errorf("unexpected payload %+v", handlingTime)
+	}
+}
@@ -0,0 +1,5 @@
+// Package shipmenttest is the validator generated by bicgen for config-productor.json.
+// It is kept in the tree to prove that generated code and bic.Validate agree.
+package shipmenttest
+
+//go:generate go run ../../../cmd/bicgen -config ../../config-productor.json -package shipmenttest -out validator.go
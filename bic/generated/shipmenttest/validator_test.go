@@ -0,0 +1,173 @@
+package shipmenttest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+// loadCorpus returns the hand written payloads plus one mutation per leaf of
+// document.json, so every generated branch is compared against the interpreter.
+func loadCorpus(t *testing.T) []string {
+	file, err := os.Open("testdata/corpus.jsonl")
+	if err != nil {
+		t.Fatalf("Error reading corpus %v", err)
+	}
+	defer file.Close()
+
+	var corpus []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		corpus = append(corpus, scanner.Text())
+	}
+
+	// Repeated keys, envelope mismatches and decoding errors, which the pass leaves to bic.Validate
+	corpus = append(corpus,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": "3"}, "lead_time": {"estimated_days": 3}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": "3", "estimated_days": null}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"x": 1}, "lead_time": null}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": 3}}, "metrics": null}`,
+		`{"entity": "SHIPMENT_TEST", "ENTITY": "ORDER", "id": "1", "metrics": {}}`,
+		`{"metrics": {"lead_time": {"estimated_days": 3}}, "id": "1", "Entity": "shipment_test"}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "ProducerToken": 5, "metrics": {}}`,
+		`{"entity": "SHIPMENT_TEST", "id": null, "metrics": {}}`,
+		`{"entity": ["SHIPMENT_TEST"], "id": "1", "metrics": {}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": []}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1"}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"LEAD_TIME": {"estimated_days": 3}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"Estimated_Days": null}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": [1], "unknown": "x", "other": null}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": "today"}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": {"a": {"b": "2019-10-11T13:38:29-03:00"}}}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": {"a": {"b": "today"}}}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {}} {}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": 1e400}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": [[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[1]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]}}}`,
+		`null`,
+		`"SHIPMENT_TEST"`,
+	)
+
+	document := map[string]interface{}{}
+	content, err := ioutil.ReadFile("../../../document.json")
+	if err != nil {
+		t.Fatalf("Error reading document %v", err)
+	}
+	json.Unmarshal(content, &document)
+	corpus = append(corpus, string(content))
+
+	replacements := []interface{}{nil, "text", 1.5, 0.0, true, []interface{}{1}, map[string]interface{}{}, map[string]interface{}{"x": 1}}
+	metrics := document["metrics"].(map[string]interface{})
+	for block, leaves := range metrics {
+		for leaf, original := range leaves.(map[string]interface{}) {
+			for _, replacement := range replacements {
+				leaves.(map[string]interface{})[leaf] = replacement
+				mutated, _ := json.Marshal(document)
+				corpus = append(corpus, string(mutated))
+			}
+			leaves.(map[string]interface{})[leaf] = original
+			leaves.(map[string]interface{})["unknown_"+leaf] = 1
+			mutated, _ := json.Marshal(document)
+			corpus = append(corpus, string(mutated))
+			delete(leaves.(map[string]interface{}), "unknown_"+leaf)
+		}
+		metrics[block+"_unknown"] = leaves
+		mutated, _ := json.Marshal(document)
+		corpus = append(corpus, string(mutated))
+		delete(metrics, block+"_unknown")
+	}
+	return corpus
+}
+
+func describe(err error) string {
+	if apiError, ok := err.(apierrors.ApiError); ok {
+		return fmt.Sprintf("%v %v %v %v", apiError.Status(), apiError.Code(), apiError.Message(), apiError.Cause())
+	}
+	return fmt.Sprint(err)
+}
+
+func TestGeneratedValidatorMatchesInterpreter(t *testing.T) {
+	previousLogger := bic.DefaultLogger
+	bic.DefaultLogger = bic.NopLogger{}
+	defer func() { bic.DefaultLogger = previousLogger }()
+
+	config, err := bic.GetProducerConfigFromFile("../../config-productor.json")
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+
+	for _, payload := range loadCorpus(t) {
+		expectedValid, expectedError := bic.Validate([]byte(payload), config)
+		valid, err := Validate([]byte(payload))
+
+		if valid != expectedValid || describe(err) != describe(expectedError) {
+			t.Errorf("%s: interpreter returned %v (%v), generated returned %v (%v)", payload, expectedValid, describe(expectedError), valid, describe(err))
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	previousLogger := bic.DefaultLogger
+	bic.DefaultLogger = bic.NopLogger{}
+	defer func() { bic.DefaultLogger = previousLogger }()
+
+	typed, err := Decode([]byte(`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": 3}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	payload := typed.(*ShipmentTestPayload)
+	if payload.Metrics.LeadTime == nil || *payload.Metrics.LeadTime.EstimatedDays != 3 || payload.Metrics.HandlingTime != nil {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	if typed, err := Decode([]byte(`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": "3"}}}`)); typed != nil || err == nil {
+		t.Errorf("invalid payloads should not be decoded, got %v", typed)
+	}
+
+	content, err := ioutil.ReadFile("../../../document.json")
+	if err != nil {
+		t.Fatalf("Error reading document %v", err)
+	}
+	scan := &payloadScan{decoder: bic.NewPayloadDecoder(content, limits), entityIndex: -1}
+	if err := scan.document(); err != nil || scan.fallback {
+		t.Errorf("expected document.json to be settled by the pass, got %v", err)
+	}
+}
+
+func BenchmarkValidators(b *testing.B) {
+	previousLogger := bic.DefaultLogger
+	bic.DefaultLogger = bic.NopLogger{}
+	defer func() { bic.DefaultLogger = previousLogger }()
+
+	config, err := bic.GetProducerConfigFromFile("../../config-productor.json")
+	if err != nil {
+		b.Fatal("Error reading config " + err.Error())
+	}
+	payloadContent, err := ioutil.ReadFile("../../../document.json")
+	if err != nil {
+		b.Fatal("Error reading document file")
+	}
+	stream := bic.NewStreamValidator(config, bic.NopLogger{})
+
+	validators := []struct {
+		name     string
+		validate func([]byte) (bool, error)
+	}{
+		{"generated", Validate},
+		{"interpreter", func(payloadContent []byte) (bool, error) { return bic.Validate(payloadContent, config) }},
+		{"stream", stream.Validate},
+	}
+	for _, validator := range validators {
+		b.Run(validator.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				validator.validate(payloadContent)
+			}
+		})
+	}
+}
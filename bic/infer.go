@@ -0,0 +1,242 @@
+package bic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// inferTypes are the leaf types tried by the Inferrer, the most specific first. date and
+// time also match datetimes, and number matches every boolean_number. A field gets the
+// first type matched by more than half of its values.
+var inferTypes = []string{"boolean_number", "number", "datetime", "date", "time", "string", "boolean", "array"}
+
+// MaxEnumValues is the largest number of distinct strings reported as an enum candidate.
+const MaxEnumValues = 10
+
+// FieldReport tells how well the samples support the type inferred for a metric.
+// Confidence is the share of non null samples matching Type, 0 when every sample was null.
+type FieldReport struct {
+	Entity     string         `json:"entity"`
+	Path       string         `json:"path"`
+	Type       string         `json:"type"`
+	Documents  int            `json:"documents"`
+	Present    int            `json:"present"`
+	Nulls      int            `json:"nulls"`
+	Nullable   bool           `json:"nullable"`
+	Mandatory  bool           `json:"mandatory"`
+	Enum       []string       `json:"enum,omitempty"`
+	Confidence float64        `json:"confidence"`
+	Seen       map[string]int `json:"seen,omitempty"`
+}
+
+// InferReport describes the samples read by an Inferrer and every inferred field.
+type InferReport struct {
+	Documents int           `json:"documents"`
+	Skipped   int           `json:"skipped"`
+	Fields    []FieldReport `json:"fields"`
+}
+
+// Inferrer builds a draft producer config from sample payloads. Payloads are grouped by
+// entity, and every metric path keeps the types its values matched.
+type Inferrer struct {
+	entities map[string]*entitySamples
+	order    []string
+	skipped  int
+}
+
+type entitySamples struct {
+	documents int
+	fields    map[string]*fieldSamples
+}
+
+type fieldSamples struct {
+	present int
+	nulls   int
+	objects int
+	leaves  int
+	matches map[string]int
+	values  map[string]bool //Distinct strings and numbers, dropped past MaxEnumValues
+}
+
+func NewInferrer() *Inferrer {
+	return &Inferrer{entities: make(map[string]*entitySamples)}
+}
+
+// Add reads one sample payload. Payloads without a valid envelope are counted as skipped
+// and their error is returned.
+func (inferrer *Inferrer) Add(content []byte) error {
+	payload, apiErr := getPayloadBody(context.Background(), NopLogger{}, "", content, DefaultLimits)
+	if apiErr == nil {
+		payload, apiErr = validatePayloadBody(NopLogger{}, "", payload)
+	}
+	if apiErr != nil {
+		inferrer.skipped++
+		return apiErr
+	}
+
+	samples, ok := inferrer.entities[payload.Entity]
+	if !ok {
+		samples = &entitySamples{fields: make(map[string]*fieldSamples)}
+		inferrer.entities[payload.Entity] = samples
+		inferrer.order = append(inferrer.order, payload.Entity)
+	}
+	samples.documents++
+	samples.add("", payload.Metrics)
+	return nil
+}
+
+func (samples *entitySamples) add(prefix string, block map[string]interface{}) {
+	for key, value := range block {
+		path := prefix + key
+		field, ok := samples.fields[path]
+		if !ok {
+			field = &fieldSamples{matches: make(map[string]int), values: make(map[string]bool)}
+			samples.fields[path] = field
+		}
+		field.present++
+
+		switch typed := value.(type) {
+		case nil:
+			field.nulls++
+		case map[string]interface{}:
+			field.objects++
+			samples.add(path+".", typed)
+		default:
+			field.leaves++
+			for _, leafType := range inferTypes {
+				if IsLeafType(value, leafType) {
+					field.matches[leafType]++
+				}
+			}
+			switch value.(type) {
+			case string, float64:
+				if field.values != nil {
+					field.values[fmt.Sprintf("%v", value)] = true
+					if len(field.values) > MaxEnumValues {
+						field.values = nil
+					}
+				}
+			}
+		}
+	}
+}
+
+// Config returns the draft config inferred from the samples added so far, with one entity
+// per entity seen, and the report of every field sorted by entity and path.
+func (inferrer *Inferrer) Config() (*StructProducerConfig, InferReport) {
+	config := &StructProducerConfig{Status: "enabled"}
+	report := InferReport{Skipped: inferrer.skipped, Fields: []FieldReport{}}
+
+	for _, entity := range inferrer.order {
+		samples := inferrer.entities[entity]
+		report.Documents += samples.documents
+
+		entityConfig := EntityConfig{Entity: entity, AllowedMetrics: make(map[string]interface{})}
+		var mandatory []string
+		paths := make([]string, 0, len(samples.fields))
+		for path := range samples.fields {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		blocks := map[string]map[string]interface{}{"": entityConfig.AllowedMetrics}
+		for _, path := range paths {
+			field := samples.fields[path]
+			parent, key := "", path
+			if dot := strings.LastIndex(path, "."); dot >= 0 {
+				parent, key = path[:dot], path[dot+1:]
+			}
+			block, ok := blocks[parent]
+			if !ok {
+				continue //The parent was inferred as a leaf
+			}
+
+			fieldReport := field.report(samples.documents)
+			fieldReport.Entity, fieldReport.Path = entity, path
+			if fieldReport.Type == "object" {
+				blocks[path] = make(map[string]interface{})
+				block[key] = blocks[path]
+			} else {
+				block[key] = fieldReport.Type
+			}
+			if fieldReport.Mandatory {
+				mandatory = append(mandatory, path)
+			}
+			report.Fields = append(report.Fields, fieldReport)
+		}
+		if len(mandatory) > 0 {
+			entityConfig.MandatoryFields = &mandatory
+		}
+		config.Entities = append(config.Entities, entityConfig)
+	}
+
+	if len(config.Entities) == 1 {
+		config.Entity = config.Entities[0].Entity
+		config.AllowedMetrics = config.Entities[0].AllowedMetrics
+		config.MandatoryFields = config.Entities[0].MandatoryFields
+		config.Entities = nil
+	}
+	sort.SliceStable(report.Fields, func(i, j int) bool {
+		return report.Fields[i].Entity < report.Fields[j].Entity
+	})
+	return config, report
+}
+
+func (field *fieldSamples) report(documents int) FieldReport {
+	report := FieldReport{
+		Documents: documents,
+		Present:   field.present,
+		Nulls:     field.nulls,
+		Nullable:  field.nulls > 0,
+		Seen:      make(map[string]int),
+	}
+	if field.objects > 0 {
+		report.Seen["object"] = field.objects
+	}
+	for leafType, count := range field.matches {
+		report.Seen[leafType] = count
+	}
+
+	if field.objects >= field.leaves && field.objects > 0 {
+		report.Type = "object"
+		report.Confidence = float64(field.objects) / float64(field.objects+field.leaves)
+		return report
+	}
+	if field.leaves == 0 {
+		report.Type = "null" //Only nulls were sent, no other value would be accepted
+		return report
+	}
+
+	//The most specific type matched by most values, the outliers lower the confidence
+	best := ""
+	for _, leafType := range inferTypes {
+		if leafType == "boolean_number" && len(field.values) < 2 {
+			continue //A constant 0 or 1 says nothing about the type
+		}
+		if field.matches[leafType]*2 > field.leaves {
+			best = leafType
+			break
+		}
+	}
+	if best == "" {
+		for _, leafType := range inferTypes[1:] {
+			if field.matches[leafType] > field.matches[best] {
+				best = leafType
+			}
+		}
+	}
+	report.Type = best
+	report.Confidence = float64(field.matches[best]) / float64(field.objects+field.leaves)
+	//Mandatory fields must be a non null leaf in every document
+	report.Mandatory = field.leaves == documents && field.matches[best] == documents
+
+	if best == "string" && field.values != nil && len(field.values) < field.leaves {
+		for value := range field.values {
+			report.Enum = append(report.Enum, value)
+		}
+		sort.Strings(report.Enum)
+	}
+	return report
+}
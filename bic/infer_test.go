@@ -0,0 +1,82 @@
+package bic
+
+import (
+	"reflect"
+	"testing"
+)
+
+var inferSamples = []string{
+	`{"entity": "SHIPMENT", "id": "1", "metrics": {"handling_time": {"date_from": "2019-10-11T13:38:29-03:00", "day": "2019-10-11", "estimated_days": 1, "is_late": 0, "status": "ok"}, "tags": ["a"]}}`,
+	`{"entity": "SHIPMENT", "id": "2", "metrics": {"handling_time": {"date_from": "2019-10-12T10:00:00-03:00", "day": "2019-10-12", "estimated_days": 2.5, "is_late": 1, "status": "late"}, "tags": null}}`,
+	`{"entity": "SHIPMENT", "id": "3", "metrics": {"handling_time": {"date_from": "2019-10-13T10:00:00-03:00", "estimated_days": 3, "is_late": 1, "status": "ok", "comment": null}}}`,
+	`{"entity": "SHIPMENT", "id": "4", "metrics": {"handling_time": {"date_from": "not a date", "estimated_days": 4, "is_late": 0, "status": "ok"}}}`,
+	`{"entity": "ORDER", "id": "5", "metrics": {"payment": {"total": 10}}}`,
+	`{"entity": "ORDER", "id": "6", "metrics": {"payment": {"total": 1}}}`,
+	`{"id": "7", "metrics": {}}`,
+}
+
+func TestInferrer(t *testing.T) {
+	captureLogs(t)
+
+	inferrer := NewInferrer()
+	for i, sample := range inferSamples {
+		err := inferrer.Add([]byte(sample))
+		if (err != nil) != (i == len(inferSamples)-1) {
+			t.Errorf("sample %v: unexpected error %v", i, err)
+		}
+	}
+	config, report := inferrer.Config()
+
+	if report.Documents != 6 || report.Skipped != 1 {
+		t.Errorf("expected 6 documents and 1 skipped, got %v and %v", report.Documents, report.Skipped)
+	}
+	entities := config.EntityConfigs()
+	if len(entities) != 2 || entities[0].Entity != "SHIPMENT" || entities[1].Entity != "ORDER" {
+		t.Fatalf("expected both entities in sample order, got %+v", entities)
+	}
+
+	expected := map[string]interface{}{
+		"handling_time": map[string]interface{}{
+			"date_from":      "datetime",
+			"day":            "date",
+			"estimated_days": "number",
+			"is_late":        "boolean_number",
+			"status":         "string",
+			"comment":        "null",
+		},
+		"tags": "array",
+	}
+	if !reflect.DeepEqual(entities[0].AllowedMetrics, expected) {
+		t.Errorf("expected %v, got %v", expected, entities[0].AllowedMetrics)
+	}
+	if entities[0].MandatoryFields == nil || !reflect.DeepEqual(*entities[0].MandatoryFields, []string{"handling_time.estimated_days", "handling_time.is_late", "handling_time.status"}) {
+		t.Errorf("unexpected mandatory fields %v", entities[0].MandatoryFields)
+	}
+	if !reflect.DeepEqual(entities[1].AllowedMetrics, map[string]interface{}{"payment": map[string]interface{}{"total": "number"}}) {
+		t.Errorf("a constant 1 is not a boolean_number, got %v", entities[1].AllowedMetrics)
+	}
+
+	fields := make(map[string]FieldReport)
+	for _, field := range report.Fields {
+		fields[field.Entity+" "+field.Path] = field
+	}
+	if field := fields["SHIPMENT handling_time.date_from"]; field.Confidence != 0.75 || field.Mandatory {
+		t.Errorf("expected a partial confidence, got %+v", field)
+	}
+	if field := fields["SHIPMENT handling_time.status"]; !reflect.DeepEqual(field.Enum, []string{"late", "ok"}) || field.Confidence != 1 {
+		t.Errorf("expected an enum candidate, got %+v", field)
+	}
+	if field := fields["SHIPMENT tags"]; !field.Nullable || field.Present != 2 || field.Documents != 4 {
+		t.Errorf("expected a nullable field, got %+v", field)
+	}
+	if field := fields["SHIPMENT handling_time.day"]; field.Enum != nil {
+		t.Errorf("unrepeated values are not enum candidates, got %+v", field)
+	}
+
+	//The draft accepts its own samples
+	for _, sample := range inferSamples[:6] {
+		if valid, err := Validate([]byte(sample), config); valid == (sample == inferSamples[3]) {
+			t.Errorf("unexpected verdict %v %v for %v", valid, err, sample)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package bic
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type JSONSchemaDraft string
+
+const (
+	JSONSchemaDraft07     JSONSchemaDraft = "draft-07"
+	JSONSchemaDraft202012 JSONSchemaDraft = "2020-12"
+)
+
+var jsonSchemaURIs = map[JSONSchemaDraft]string{
+	JSONSchemaDraft07:     "http://json-schema.org/draft-07/schema#",
+	JSONSchemaDraft202012: "https://json-schema.org/draft/2020-12/schema",
+}
+
+// ToJSONSchema returns the draft-07 JSON Schema of the payloads accepted by config.
+func ToJSONSchema(config *StructProducerConfig) ([]byte, error) {
+	return ToJSONSchemaDraft(config, JSONSchemaDraft07)
+}
+
+// ToJSONSchemaDraft returns the JSON Schema of the payloads accepted by config, following
+// the rules of Validate:
+//
+//   - the envelope requires a non empty id and entity, an object metrics and allows a
+//     string version. The entity must be one of the configured ones, following the
+//     entity_match policy.
+//   - metrics only allow their configured keys, and mandatory_fields become required,
+//     non null properties. Null values are accepted anywhere, objects sent for a leaf
+//     must hold values of the leaf type and top level leaves are not checked.
+//   - leaf types become JSON Schema types, date, time and datetime add the format named
+//     by LeafFormat, which jschema registers, and boolean_number only allows 0 and 1.
+//
+// When the config declares several entities, the metrics of each one apply through an
+// if/then on the entity. Envelope keys match exactly, while Validate ignores their case,
+// and the decoding limits are not part of the schema.
+func ToJSONSchemaDraft(config *StructProducerConfig, draft JSONSchemaDraft) ([]byte, error) {
+	uri, ok := jsonSchemaURIs[draft]
+	if !ok {
+		return nil, fmt.Errorf("unsupported JSON Schema draft %q, expected %v or %v", draft, JSONSchemaDraft07, JSONSchemaDraft202012)
+	}
+
+	builder := &jsonSchemaBuilder{definitionsKey: "definitions", definitions: make(map[string]interface{})}
+	if draft == JSONSchemaDraft202012 {
+		builder.definitionsKey = "$defs"
+	}
+
+	entityConfigs := config.EntityConfigs()
+	entityPatterns := make([]string, len(entityConfigs))
+	for i := range entityConfigs {
+		entityPatterns[i] = entityPattern(config.EntityMatch, entityConfigs[i].Entity)
+	}
+
+	properties := map[string]interface{}{
+		"id":      map[string]interface{}{"type": "string", "minLength": 1},
+		"entity":  map[string]interface{}{"type": "string", "pattern": "^(" + strings.Join(entityPatterns, "|") + ")$"},
+		"version": map[string]interface{}{"type": "string"},
+		"metrics": map[string]interface{}{"type": "object"},
+	}
+	schema := map[string]interface{}{
+		"$schema":    uri,
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"entity", "id", "metrics"},
+	}
+	if config.ProducerName != "" {
+		schema["title"] = config.ProducerName
+	}
+
+	if len(entityConfigs) == 1 {
+		properties["metrics"] = builder.metricsSchema(&entityConfigs[0])
+	} else if len(entityConfigs) > 1 {
+		var conditions []interface{}
+		for i := range entityConfigs {
+			conditions = append(conditions, map[string]interface{}{
+				"if": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"entity": map[string]interface{}{"pattern": "^" + entityPatterns[i] + "$"},
+					},
+				},
+				"then": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"metrics": builder.metricsSchema(&entityConfigs[i]),
+					},
+				},
+			})
+		}
+		schema["allOf"] = conditions
+	}
+
+	if len(builder.definitions) > 0 {
+		schema[builder.definitionsKey] = builder.definitions
+	}
+	return json.MarshalIndent(schema, "", "    ")
+}
+
+// entityPattern matches the entity name, with case insensitive letters unless the policy
+// is exact. JSON Schema patterns have no flags, so every letter becomes a class.
+func entityPattern(policy EntityMatchPolicy, entity string) string {
+	quoted := regexp.QuoteMeta(entity)
+	if policy == EntityMatchExact {
+		return quoted
+	}
+	var pattern strings.Builder
+	for _, r := range quoted {
+		upper, lower := unicode.ToUpper(r), unicode.ToLower(r)
+		if upper == lower {
+			pattern.WriteRune(r)
+			continue
+		}
+		pattern.WriteString("[" + string(upper) + string(lower) + "]")
+	}
+	return pattern.String()
+}
+
+// mandatoryTree holds the lower cased mandatory paths split by level, the empty key marks
+// a path end.
+type mandatoryTree map[string]mandatoryTree
+
+func newMandatoryTree(mandatoryFields *[]string) mandatoryTree {
+	mandatory := mandatoryTree{}
+	if mandatoryFields == nil {
+		return mandatory
+	}
+	for _, path := range *mandatoryFields {
+		level := mandatory
+		for _, key := range strings.Split(strings.ToLower(path), ".") {
+			if level[key] == nil {
+				level[key] = mandatoryTree{}
+			}
+			level = level[key]
+		}
+		level[""] = mandatoryTree{}
+	}
+	return mandatory
+}
+
+// isEnd reports whether a mandatory path ends at this level.
+func (mandatory mandatoryTree) isEnd() bool {
+	_, ok := mandatory[""]
+	return ok
+}
+
+// nullsDefinition names the values made only of nulls, which Validate accepts under any key.
+const nullsDefinition = "nulls"
+
+// anyLeaf is a non null value that is not an object.
+var anyLeaf = map[string]interface{}{"type": []string{"string", "number", "boolean", "array"}}
+
+type jsonSchemaBuilder struct {
+	definitionsKey string
+	definitions    map[string]interface{}
+}
+
+// ref returns a reference to the definition of a leaf type. The definition accepts the
+// values of the type, null and objects whose values follow the same definition.
+func (builder *jsonSchemaBuilder) ref(leafType string) map[string]interface{} {
+	value, known := leafValueSchema(leafType).(map[string]interface{})
+	if !known {
+		leafType = nullsDefinition
+	}
+	reference := map[string]interface{}{"$ref": "#/" + builder.definitionsKey + "/" + leafType}
+	if _, defined := builder.definitions[leafType]; defined {
+		return reference
+	}
+
+	definition := map[string]interface{}{"type": []string{"null", "object"}}
+	if known {
+		for keyword, schema := range value {
+			definition[keyword] = schema
+		}
+		definition["type"] = []string{value["type"].(string), "null", "object"}
+	}
+	definition["additionalProperties"] = reference
+	builder.definitions[leafType] = definition
+	return reference
+}
+
+func (builder *jsonSchemaBuilder) metricsSchema(entityConfig *EntityConfig) map[string]interface{} {
+	mandatory := newMandatoryTree(entityConfig.MandatoryFields)
+	schema := builder.objectSchema(entityConfig.AllowedMetrics, mandatory, true)
+	schema["type"] = "object"
+	//Top level leaves are never checked, unknown objects may only hold nulls
+	schema["additionalProperties"] = map[string]interface{}{"additionalProperties": builder.ref(nullsDefinition)}
+	return schema
+}
+
+// objectSchema returns the properties of a metric object. topLevel objects are the
+// direct children of metrics.
+func (builder *jsonSchemaBuilder) objectSchema(block map[string]interface{}, mandatory mandatoryTree, topLevel bool) map[string]interface{} {
+	properties := make(map[string]interface{}, len(block))
+	required := []string{}
+	for key, value := range block {
+		subtree := mandatory[strings.ToLower(key)]
+		properties[key] = builder.metricSchema(value, subtree, topLevel)
+		if subtree != nil {
+			required = append(required, key)
+		}
+	}
+	for key, subtree := range mandatory {
+		if key == "" || hasKeyFold(block, key) {
+			continue
+		}
+		//Mandatory fields outside allowed_metrics can't be sent, unless they are top level leaves
+		if topLevel && subtree.isEnd() && len(subtree) == 1 {
+			properties[key] = anyLeaf
+		} else {
+			properties[key] = false
+		}
+		required = append(required, key)
+	}
+
+	schema := map[string]interface{}{
+		"properties":           properties,
+		"additionalProperties": builder.ref(nullsDefinition),
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// metricSchema returns the schema of a metric configured as config. mandatory is nil
+// unless a mandatory field goes through the metric.
+func (builder *jsonSchemaBuilder) metricSchema(config interface{}, mandatory mandatoryTree, topLevel bool) interface{} {
+	block, isObject := config.(map[string]interface{})
+	leafType := fmt.Sprintf("%v", config)
+
+	switch {
+	case topLevel && mandatory.isEnd() && !isObject:
+		//Only sent objects are checked, the reference keeps the configured type
+		return map[string]interface{}{"type": anyLeaf["type"], "additionalProperties": builder.ref(leafType)}
+	case topLevel && mandatory.isEnd():
+		return anyLeaf
+	case topLevel && isObject:
+		schema := builder.objectSchema(block, mandatory, false)
+		if mandatory != nil {
+			schema["type"] = "object"
+		}
+		return schema
+	case topLevel:
+		return map[string]interface{}{"additionalProperties": builder.ref(leafType)}
+	case isObject && mandatory.isEnd():
+		return false //Objects can't be sent as a leaf
+	case isObject:
+		schema := builder.objectSchema(block, mandatory, false)
+		schema["type"] = nullableType("object", mandatory == nil)
+		return schema
+	case mandatory != nil:
+		return leafValueSchema(leafType)
+	}
+	return builder.ref(leafType)
+}
+
+func hasKeyFold(block map[string]interface{}, key string) bool {
+	for blockKey := range block {
+		if strings.EqualFold(blockKey, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// leafValueSchema returns the schema of the non null values of a leaf type, false when
+// the type is unknown.
+func leafValueSchema(leafType string) interface{} {
+	switch leafType {
+	case "number", "string", "array":
+		return map[string]interface{}{"type": leafType}
+	case "bool", "boolean":
+		return map[string]interface{}{"type": "boolean"}
+	case "boolean_number":
+		return map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1, "multipleOf": 1}
+	case "date", "time", "datetime":
+		return map[string]interface{}{"type": "string", "format": LeafFormat(leafType)}
+	}
+	return false
+}
+
+func nullableType(jsonType string, nullable bool) interface{} {
+	if nullable {
+		return []string{jsonType, "null"}
+	}
+	return jsonType
+}
@@ -0,0 +1,498 @@
+package bic
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaIssue is a part of a JSON Schema that a producer config can't represent. Path is
+// the JSON Pointer of the schema object holding the keyword.
+type SchemaIssue struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Reason  string `json:"reason"`
+}
+
+func (issue SchemaIssue) String() string {
+	if issue.Keyword == "" {
+		return fmt.Sprintf("%v: %v", issue.Path, issue.Reason)
+	}
+	return fmt.Sprintf("%v/%v: %v", issue.Path, issue.Keyword, issue.Reason)
+}
+
+// schemaAnnotations don't affect validation, they are dropped without an issue.
+var schemaAnnotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true,
+	"default": true, "examples": true, "readOnly": true, "writeOnly": true, "deprecated": true,
+}
+
+// FromJSONSchema builds the producer config of the payloads described by a JSON Schema,
+// reading entity and metrics from the envelope. The schemas written by ToJSONSchema are
+// imported without loss. Keywords the config can't represent, such as dependencies,
+// oneOf or patternProperties, are ignored and listed as issues.
+func FromJSONSchema(schema []byte) (*StructProducerConfig, []SchemaIssue, error) {
+	var root interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil, nil, err
+	}
+	rootObject, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, nil, fmt.Errorf("the schema must be an object")
+	}
+
+	importer := &schemaImporter{root: rootObject, reported: make(map[string]bool)}
+	config, err := importer.envelope()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(importer.issues, func(i, j int) bool {
+		return importer.issues[i].String() < importer.issues[j].String()
+	})
+	return config, importer.issues, nil
+}
+
+type schemaImporter struct {
+	root     map[string]interface{}
+	issues   []SchemaIssue
+	reported map[string]bool //Definitions can be reached more than once
+}
+
+func (importer *schemaImporter) issue(path string, keyword string, reason string) {
+	issue := SchemaIssue{Path: path, Keyword: keyword, Reason: reason}
+	if !importer.reported[issue.String()] {
+		importer.reported[issue.String()] = true
+		importer.issues = append(importer.issues, issue)
+	}
+}
+
+// unsupported reports every keyword of object that was not consumed.
+func (importer *schemaImporter) unsupported(object map[string]interface{}, path string, consumed ...string) {
+	for keyword := range object {
+		if schemaAnnotations[keyword] || contains(consumed, keyword) {
+			continue
+		}
+		importer.issue(path, keyword, "not supported by producer configs")
+	}
+}
+
+// resolve follows local references, returning the referenced schema and its path.
+func (importer *schemaImporter) resolve(node interface{}, path string) (interface{}, string) {
+	for depth := 0; depth < DefaultLimits.MaxDepth; depth++ {
+		object, isObject := node.(map[string]interface{})
+		if !isObject {
+			return node, path
+		}
+		ref, isRef := object["$ref"].(string)
+		if !isRef {
+			return node, path
+		}
+		importer.unsupported(object, path, "$ref")
+		if !strings.HasPrefix(ref, "#/") {
+			importer.issue(path, "$ref", "only local references are supported")
+			return true, path
+		}
+
+		var target interface{} = importer.root
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+			parent, isParent := target.(map[string]interface{})
+			if target, isParent = parent[token]; !isParent {
+				importer.issue(path, "$ref", fmt.Sprintf("%v not found", ref))
+				return true, path
+			}
+		}
+		node, path = target, ref[1:]
+	}
+	importer.issue(path, "$ref", "too many nested references")
+	return true, path
+}
+
+func (importer *schemaImporter) envelope() (*StructProducerConfig, error) {
+	properties, _ := importer.root["properties"].(map[string]interface{})
+	if _, hasMetrics := properties["metrics"]; !hasMetrics {
+		return nil, fmt.Errorf("the schema has no metrics property")
+	}
+
+	config := &StructProducerConfig{Status: "enabled"}
+	config.ProducerName, _ = importer.root["title"].(string)
+	importer.unsupported(importer.root, "", "type", "properties", "required", "definitions", "$defs", "allOf")
+
+	for key := range properties {
+		if key != "entity" && key != "id" && key != "version" && key != "metrics" {
+			importer.issue("/properties", key, "envelope properties other than entity, id, version and metrics are ignored")
+		}
+	}
+	for _, key := range stringList(importer.root["required"]) {
+		if key == "version" {
+			importer.issue("", "required", "version can't be mandatory")
+		}
+	}
+
+	policy := EntityMatchCaseInsensitive
+	if conditions, ok := importer.entityConditions(); ok {
+		for _, condition := range conditions {
+			entityConfig := importer.entity(condition.metrics, condition.metricsPath)
+			entityConfig.Entity = condition.entity
+			config.Entities = append(config.Entities, entityConfig)
+			policy = condition.policy
+		}
+	} else {
+		var names []string
+		names, policy = importer.entityNames(properties["entity"], "/properties/entity")
+		entityConfig := importer.entity(properties["metrics"], "/properties/metrics")
+		for i, name := range names {
+			if i == 0 {
+				config.Entity = name
+				config.AllowedMetrics = entityConfig.AllowedMetrics
+				config.MandatoryFields = entityConfig.MandatoryFields
+				continue
+			}
+			entityConfig.Entity = name
+			config.Entities = append(config.Entities, entityConfig)
+		}
+		if len(names) == 0 {
+			config.AllowedMetrics = entityConfig.AllowedMetrics
+			config.MandatoryFields = entityConfig.MandatoryFields
+		}
+	}
+	if policy == EntityMatchExact {
+		config.EntityMatch = EntityMatchExact
+	}
+	return config, nil
+}
+
+type entityCondition struct {
+	entity      string
+	policy      EntityMatchPolicy
+	metrics     interface{}
+	metricsPath string
+}
+
+// entityConditions reads the allOf if/then written by ToJSONSchema for several entities.
+func (importer *schemaImporter) entityConditions() ([]entityCondition, bool) {
+	allOf, ok := importer.root["allOf"].([]interface{})
+	if !ok {
+		if _, present := importer.root["allOf"]; present {
+			importer.issue("", "allOf", "not supported by producer configs")
+		}
+		return nil, false
+	}
+
+	var conditions []entityCondition
+	for i, item := range allOf {
+		path := fmt.Sprintf("/allOf/%v", i)
+		condition, isObject := item.(map[string]interface{})
+		entityPattern, _ := lookup(condition, "if", "properties", "entity", "pattern").(string)
+		metrics := lookup(condition, "then", "properties", "metrics")
+		if !isObject || entityPattern == "" || metrics == nil {
+			importer.issue("", "allOf", "only entity conditions are supported")
+			return nil, false
+		}
+		names, policy, parsed := parseEntityPattern(entityPattern)
+		if !parsed || len(names) != 1 {
+			importer.issue(path+"/if/properties/entity", "pattern", "only entity names are supported")
+			return nil, false
+		}
+		conditions = append(conditions, entityCondition{entity: names[0], policy: policy, metrics: metrics, metricsPath: path + "/then/properties/metrics"})
+	}
+	return conditions, true
+}
+
+func (importer *schemaImporter) entityNames(node interface{}, path string) ([]string, EntityMatchPolicy) {
+	node, path = importer.resolve(node, path)
+	object, _ := node.(map[string]interface{})
+	importer.unsupported(object, path, "type", "const", "enum", "pattern")
+
+	if name, ok := object["const"].(string); ok {
+		return []string{name}, EntityMatchExact
+	}
+	if names := stringList(object["enum"]); len(names) > 0 {
+		return names, EntityMatchExact
+	}
+	if pattern, ok := object["pattern"].(string); ok {
+		if names, policy, parsed := parseEntityPattern(pattern); parsed {
+			return names, policy
+		}
+		importer.issue(path, "pattern", "only entity names are supported")
+		return nil, EntityMatchCaseInsensitive
+	}
+	importer.issue(path, "", "the entity is not constrained by const, enum or pattern, set it in the config")
+	return nil, EntityMatchCaseInsensitive
+}
+
+// parseEntityPattern reads the names of a pattern written by entityPattern, where letters
+// are classes like [Aa] unless the match is exact.
+func parseEntityPattern(pattern string) ([]string, EntityMatchPolicy, bool) {
+	if !strings.HasPrefix(pattern, "^") || !strings.HasSuffix(pattern, "$") {
+		return nil, "", false
+	}
+	pattern = pattern[1 : len(pattern)-1]
+	if strings.HasPrefix(pattern, "(") && strings.HasSuffix(pattern, ")") {
+		pattern = pattern[1 : len(pattern)-1]
+	}
+
+	policy := EntityMatchExact
+	var names []string
+	for _, alternative := range strings.Split(pattern, "|") {
+		var name strings.Builder
+		runes := []rune(alternative)
+		for i := 0; i < len(runes); i++ {
+			switch {
+			case runes[i] == '\\' && i+1 < len(runes):
+				i++
+				name.WriteRune(runes[i])
+			case runes[i] == '[' && i+3 < len(runes) && runes[i+3] == ']':
+				name.WriteRune(runes[i+1])
+				policy = EntityMatchCaseInsensitive
+				i += 3
+			case strings.ContainsRune(`.+*?()[]{}^$`, runes[i]):
+				return nil, "", false
+			default:
+				name.WriteRune(runes[i])
+			}
+		}
+		names = append(names, name.String())
+	}
+	return names, policy, true
+}
+
+func (importer *schemaImporter) entity(metrics interface{}, path string) EntityConfig {
+	entityConfig := EntityConfig{}
+	value, mandatory, _ := importer.metric(metrics, path)
+	entityConfig.AllowedMetrics, _ = value.(map[string]interface{})
+	if entityConfig.AllowedMetrics == nil {
+		importer.issue(path, "type", "metrics must be an object")
+	}
+	if len(mandatory) > 0 {
+		entityConfig.MandatoryFields = &mandatory
+	}
+	return entityConfig
+}
+
+// metric returns the allowed_metrics value described by node and the mandatory paths
+// below it. ok is false when the node accepts no metric value.
+func (importer *schemaImporter) metric(node interface{}, path string) (value interface{}, mandatory []string, ok bool) {
+	node, path = importer.resolve(node, path)
+	if accept, isBool := node.(bool); isBool {
+		if accept {
+			importer.issue(path, "", "a schema accepting any value can't be represented")
+		}
+		return nil, nil, false
+	}
+	object, _ := node.(map[string]interface{})
+
+	types := stringList(object["type"])
+	if name, isString := object["type"].(string); isString {
+		types = []string{name}
+	}
+	var leafTypes []string
+	hasObject := false
+	for _, name := range types {
+		switch name {
+		case "null":
+		case "object":
+			hasObject = true
+		default:
+			leafTypes = append(leafTypes, name)
+		}
+	}
+
+	_, hasProperties := object["properties"]
+	switch {
+	case hasProperties || (hasObject && len(leafTypes) == 0 && !isNullsSchema(object)):
+		return importer.block(object, path)
+	case len(leafTypes) == 4 && isAnyLeaf(leafTypes):
+		//A top level mandatory leaf, only sent objects are checked against the type
+		if _, hasType := object["additionalProperties"]; hasType {
+			value, _, ok = importer.metric(object["additionalProperties"], path+"/additionalProperties")
+			importer.unsupported(object, path, "type", "additionalProperties")
+			return value, nil, ok
+		}
+		importer.unsupported(object, path, "type")
+		return nil, nil, false
+	case len(leafTypes) == 0 && !hasObject && object["additionalProperties"] != nil:
+		//A top level leaf, only sent objects are checked against the type
+		importer.unsupported(object, path, "additionalProperties")
+		value, _, ok = importer.metric(object["additionalProperties"], path+"/additionalProperties")
+		return value, nil, ok
+	}
+
+	if len(leafTypes) > 1 {
+		importer.issue(path, "type", fmt.Sprintf("only one type is supported, %v is used", leafTypes[0]))
+	}
+	leafType := "null" //No value other than null is accepted
+	if len(leafTypes) > 0 {
+		leafType = leafTypes[0]
+	}
+	consumed := []string{"type"}
+	if hasObject {
+		consumed = append(consumed, "additionalProperties") //Objects sent for a leaf, which Validate walks
+	}
+	value, leafConsumed := importer.leafType(object, path, leafType)
+	importer.unsupported(object, path, append(consumed, leafConsumed...)...)
+	return value, nil, true
+}
+
+// leafType maps a JSON Schema type to the bic leaf type, with the keywords it consumed.
+func (importer *schemaImporter) leafType(object map[string]interface{}, path string, jsonType string) (string, []string) {
+	switch jsonType {
+	case "integer":
+		importer.issue(path, "type", "integer is imported as number")
+		return "number", nil
+	case "number":
+		minimum, _ := object["minimum"].(float64)
+		maximum, _ := object["maximum"].(float64)
+		multipleOf, _ := object["multipleOf"].(float64)
+		if object["minimum"] != nil && minimum == 0 && maximum == 1 && multipleOf == 1 {
+			return "boolean_number", []string{"minimum", "maximum", "multipleOf"}
+		}
+		return "number", nil
+	case "string":
+		format, _ := object["format"].(string)
+		for leafType, standardFormat := range map[string]string{"date": "date", "time": "time", "datetime": "date-time"} {
+			if format == LeafFormat(leafType) {
+				return leafType, []string{"format"}
+			}
+			if format == standardFormat {
+				importer.issue(path, "format", fmt.Sprintf("%v is imported as %v, which only looks for the format inside the value", format, leafType))
+				return leafType, []string{"format"}
+			}
+		}
+		return "string", nil
+	case "boolean", "array":
+		return jsonType, nil
+	case "null":
+		if isBooleanNumberEnum(object["enum"]) {
+			return "boolean_number", []string{"enum"}
+		}
+		return "null", nil
+	}
+	importer.issue(path, "type", fmt.Sprintf("unknown type %v", jsonType))
+	return jsonType, nil
+}
+
+func (importer *schemaImporter) block(object map[string]interface{}, path string) (interface{}, []string, bool) {
+	importer.unsupported(object, path, "type", "properties", "required", "additionalProperties")
+
+	properties, _ := object["properties"].(map[string]interface{})
+	block := make(map[string]interface{}, len(properties))
+	childMandatory := make(map[string][]string)
+	for key, child := range properties {
+		value, mandatory, ok := importer.metric(child, path+"/properties/"+escapePointer(key))
+		if ok {
+			block[key] = value
+		}
+		childMandatory[key] = mandatory
+	}
+
+	required := stringList(object["required"])
+	var mandatory []string
+	for _, key := range required {
+		_, isBlock := block[key].(map[string]interface{})
+		switch {
+		case !isBlock:
+			mandatory = append(mandatory, key)
+		case len(childMandatory[key]) == 0:
+			importer.issue(path, "required", fmt.Sprintf("%v is an object, only leaves can be mandatory", key))
+		}
+		for _, subPath := range childMandatory[key] {
+			mandatory = append(mandatory, key+"."+subPath)
+		}
+	}
+	for key, subPaths := range childMandatory {
+		if len(subPaths) > 0 && !contains(required, key) {
+			importer.issue(path+"/properties/"+escapePointer(key), "required", "fields required only when their parent is sent can't be mandatory")
+		}
+	}
+	sort.Strings(mandatory)
+
+	switch additional := object["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		if additional {
+			importer.issue(path, "additionalProperties", "unknown metrics are always rejected")
+		}
+	default:
+		resolved, _ := importer.resolve(additional, path+"/additionalProperties")
+		if resolvedObject, ok := resolved.(map[string]interface{}); !ok || !(isNullsSchema(resolvedObject) || isNullsOrLeafSchema(importer, resolvedObject)) {
+			importer.issue(path, "additionalProperties", "unknown metrics are always rejected")
+		}
+	}
+	return block, mandatory, true
+}
+
+// isNullsSchema reports the nulls definition, values made only of nulls.
+func isNullsSchema(object map[string]interface{}) bool {
+	types := stringList(object["type"])
+	return len(types) == 2 && contains(types, "null") && contains(types, "object") && object["enum"] == nil
+}
+
+// isNullsOrLeafSchema reports the additionalProperties of metrics, where unknown top level
+// leaves are accepted and unknown objects may only hold nulls.
+func isNullsOrLeafSchema(importer *schemaImporter, object map[string]interface{}) bool {
+	if len(object) != 1 || object["additionalProperties"] == nil {
+		return false
+	}
+	resolved, _ := importer.resolve(object["additionalProperties"], "")
+	nulls, ok := resolved.(map[string]interface{})
+	return ok && isNullsSchema(nulls)
+}
+
+func isAnyLeaf(types []string) bool {
+	for _, name := range anyLeaf["type"].([]string) {
+		if !contains(types, name) {
+			return false
+		}
+	}
+	return true
+}
+
+func isBooleanNumberEnum(enum interface{}) bool {
+	values, ok := enum.([]interface{})
+	if !ok || len(values) == 0 {
+		return false
+	}
+	for _, value := range values {
+		if value != nil && value != 0.0 && value != 1.0 {
+			return false
+		}
+	}
+	return true
+}
+
+func lookup(node interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = object[key]
+	}
+	return node
+}
+
+func stringList(value interface{}) []string {
+	values, _ := value.([]interface{})
+	var list []string
+	for _, value := range values {
+		if text, ok := value.(string); ok {
+			list = append(list, text)
+		}
+	}
+	return list
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func escapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
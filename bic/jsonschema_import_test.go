@@ -0,0 +1,152 @@
+package bic
+
+import (
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func mandatorySet(fields *[]string) []string {
+	var set []string
+	if fields != nil {
+		for _, field := range *fields {
+			set = append(set, strings.ToLower(field))
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+func TestFromJSONSchemaRoundTrip(t *testing.T) {
+	productorConfig, err := GetProducerConfigFromFile("config-productor.json")
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+	configs := map[string]*StructProducerConfig{"config-productor.json": productorConfig}
+	for name, content := range map[string][]byte{"mandatory": mandatoryConfig, "multiple entities": multiEntityConfig, "leaves": leafConfig} {
+		config, err := GetProducerConfig("1", content)
+		if err != nil {
+			t.Fatalf("Error reading config %v", err)
+		}
+		configs[name] = config
+	}
+
+	for name, config := range configs {
+		for _, draft := range []JSONSchemaDraft{JSONSchemaDraft07, JSONSchemaDraft202012} {
+			schema, err := ToJSONSchemaDraft(config, draft)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			imported, issues, err := FromJSONSchema(schema)
+			if err != nil {
+				t.Fatalf("%v %v: unexpected error %v", name, draft, err)
+			}
+			if len(issues) > 0 {
+				t.Errorf("%v %v: unexpected issues %v", name, draft, issues)
+			}
+			if imported.ProducerName != config.ProducerName || imported.EntityMatch != config.EntityMatch && config.EntityMatch == EntityMatchExact {
+				t.Errorf("%v %v: expected producer %v and match %v, got %v and %v", name, draft, config.ProducerName, config.EntityMatch, imported.ProducerName, imported.EntityMatch)
+			}
+
+			expected, got := config.EntityConfigs(), imported.EntityConfigs()
+			if len(expected) != len(got) {
+				t.Fatalf("%v %v: expected %v entities, got %v", name, draft, len(expected), len(got))
+			}
+			for i := range expected {
+				if name == "leaves" {
+					//Unknown types only accept nulls, which is what the null type means
+					expected[i].AllowedMetrics["block"].(map[string]interface{})["kind"] = "null"
+				}
+				if got[i].Entity != expected[i].Entity {
+					t.Errorf("%v %v: expected entity %v, got %v", name, draft, expected[i].Entity, got[i].Entity)
+				}
+				if !reflect.DeepEqual(got[i].AllowedMetrics, expected[i].AllowedMetrics) {
+					t.Errorf("%v %v: expected metrics %v, got %v", name, draft, expected[i].AllowedMetrics, got[i].AllowedMetrics)
+				}
+				if !reflect.DeepEqual(mandatorySet(got[i].MandatoryFields), mandatorySet(expected[i].MandatoryFields)) {
+					t.Errorf("%v %v: expected mandatory fields %v, got %v", name, draft, mandatorySet(expected[i].MandatoryFields), mandatorySet(got[i].MandatoryFields))
+				}
+			}
+		}
+	}
+}
+
+func TestFromJSONSchemaIssues(t *testing.T) {
+	schema, err := ioutil.ReadFile("../jschema/schema.json")
+	if err != nil {
+		t.Fatalf("Error reading schema %v", err)
+	}
+	config, issues, err := FromJSONSchema(schema)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(config.AllowedMetrics) == 0 {
+		t.Errorf("expected the metrics of the schema, got %v", config.AllowedMetrics)
+	}
+
+	var keywords []string
+	for _, issue := range issues {
+		keywords = append(keywords, issue.Keyword)
+	}
+	for _, keyword := range []string{"required", "type"} {
+		if !contains(keywords, keyword) {
+			t.Errorf("expected an issue for %v, got %v", keyword, issues)
+		}
+	}
+
+	_, issues, err = FromJSONSchema([]byte(`{
+		"properties": {
+			"entity": {"const": "ORDER"},
+			"metrics": {
+				"type": "object",
+				"properties": {
+					"payment": {"type": "object", "oneOf": [{}], "properties": {"status": {"type": "string"}}},
+					"tags": {"type": "object", "patternProperties": {"^x": {"type": "string"}}}
+				},
+				"dependencies": {"payment": ["tags"]}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{
+		"/properties/metrics/dependencies: not supported by producer configs",
+		"/properties/metrics/properties/payment/oneOf: not supported by producer configs",
+		"/properties/metrics/properties/tags/patternProperties: not supported by producer configs",
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if _, _, err := FromJSONSchema([]byte(`{"properties": {}}`)); err == nil {
+		t.Errorf("expected an error for a schema without metrics")
+	}
+}
+
+func TestParseEntityPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		names   []string
+		policy  EntityMatchPolicy
+		ok      bool
+	}{
+		{"^(SHIPMENT|ORDER)$", []string{"SHIPMENT", "ORDER"}, EntityMatchExact, true},
+		{"^([Ss][Hh]IP_1)$", []string{"SHIP_1"}, EntityMatchCaseInsensitive, true},
+		{`^a\.b$`, []string{"a.b"}, EntityMatchExact, true},
+		{"^SHIP.*$", nil, "", false},
+		{"SHIPMENT", nil, "", false},
+	}
+	for _, test := range tests {
+		names, policy, ok := parseEntityPattern(test.pattern)
+		if ok != test.ok || policy != test.policy || !reflect.DeepEqual(names, test.names) {
+			t.Errorf("%v: expected %v %v %v, got %v %v %v", test.pattern, test.names, test.policy, test.ok, names, policy, ok)
+		}
+	}
+}
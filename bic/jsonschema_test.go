@@ -0,0 +1,160 @@
+package bic
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var leafConfig = []byte(`{
+	"id": "5",
+	"entity": "SHIPMENT_TEST",
+	"status": "enabled",
+	"allowed_metrics": {
+		"count": "number",
+		"block": {"flag": "boolean_number", "when": "date", "kind": "unknown_type", "tags": "array"}
+	},
+	"mandatory_fields": ["count", "block.flag"]
+}`)
+
+// jschema registers the leaf formats, and imports this package.
+func init() {
+	for _, leafType := range []string{"boolean_number", "date", "time", "datetime"} {
+		gojsonschema.FormatCheckers.Add(LeafFormat(leafType), LeafFormatChecker(leafType))
+	}
+}
+
+// schemaDivergence reports payloads whose verdict can't be expressed by a JSON Schema:
+// envelope keys in another case, data after the document and the decoding limits.
+func schemaDivergence(payload string) bool {
+	return strings.Contains(payload, `"ENTITY"`) || strings.HasSuffix(payload, "} {}") || strings.Contains(payload, strings.Repeat("[", DefaultLimits.MaxDepth))
+}
+
+func compileJSONSchema(t *testing.T, config *StructProducerConfig) *gojsonschema.Schema {
+	source, err := ToJSONSchema(config)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(source))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v\n%s", err, source)
+	}
+	return schema
+}
+
+// TestToJSONSchemaAgreesWithValidate checks that both engines give the same verdict when
+// they are driven by the same config.
+func TestToJSONSchemaAgreesWithValidate(t *testing.T) {
+	captureLogs(t)
+
+	productorConfig, err := GetProducerConfigFromFile("config-productor.json")
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+	configs := map[string]*StructProducerConfig{"config-productor.json": productorConfig}
+	for name, content := range map[string][]byte{"mandatory": mandatoryConfig, "multiple entities": multiEntityConfig, "leaves": leafConfig} {
+		config, err := GetProducerConfig("1", content)
+		if err != nil {
+			t.Fatalf("Error reading config %v", err)
+		}
+		configs[name] = config
+	}
+
+	payloads := append([]string{
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {"handling_time": {"estimated_days": null}}}`,
+		`{"entity": "SHIPMENT", "id": "1", "metrics": {}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"payment": {"status": "approved"}}}`,
+		`{"entity": "ORDER", "id": "1", "metrics": {"handling_time": {"estimated_days": 2}}}`,
+		`{"entity": "order", "id": "1", "metrics": {}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "", "metrics": {}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1"}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {}, "version": "0.0.1"}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": null}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": true}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"is_late": 1, "estimated_days": 3}, "handling_time": {"estimated_days": 1}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"is_late": 2, "estimated_days": 3}, "handling_time": {"estimated_days": 1}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": 3}, "handling_time": {"estimated_days": 1, "flags": [1, 2]}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": 3}, "handling_time": {"estimated_days": 1, "flags": "1"}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": 3}, "handling_time": {"estimated_days": null}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": "not checked", "block": {"flag": 1}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": {"nested": 2}, "block": {"flag": 0}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": {"nested": "2"}, "block": {"flag": 0}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": 1, "block": {"flag": 0.5}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": 1, "block": {"flag": null}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": 1, "block": {"flag": 1, "when": "2020-06-04", "tags": ["a"]}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": 1, "block": {"flag": 1, "kind": "anything"}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": 1, "block": {"flag": 1, "kind": null, "other": {"deep": null}}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": 1, "block": {"flag": {"nested": 1}}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": null, "block": {"flag": 1}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"count": 1, "block": 5}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": "2019-10-11"}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": "13:38:29"}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": "2019-10-11T13:38:29.5"}}}`,
+		`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": "x2019-10-11T13:38:29Zx"}}}`,
+	}, streamCorpus...)
+
+	for name, config := range configs {
+		schema := compileJSONSchema(t, config)
+		for _, payload := range payloads {
+			if schemaDivergence(payload) {
+				continue
+			}
+			valid, _ := Validate([]byte(payload), config)
+			result, err := schema.Validate(gojsonschema.NewStringLoader(payload))
+			if err != nil {
+				if valid {
+					t.Errorf("%v: schema could not read %v: %v", name, payload, err)
+				}
+				continue
+			}
+			if result.Valid() != valid {
+				t.Errorf("%v: Validate says %v, the schema says %v (%v) for %v", name, valid, result.Valid(), result.Errors(), payload)
+			}
+		}
+	}
+}
+
+func TestToJSONSchemaDrafts(t *testing.T) {
+	config, _ := GetProducerConfig("1", mandatoryConfig)
+
+	source, err := ToJSONSchemaDraft(config, JSONSchemaDraft202012)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var schema struct {
+		Schema     string `json:"$schema"`
+		Properties struct {
+			Metrics struct {
+				Required   []string `json:"required"`
+				Properties map[string]struct {
+					Required []string `json:"required"`
+				} `json:"properties"`
+			} `json:"metrics"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(source, &schema); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if schema.Schema != "https://json-schema.org/draft/2020-12/schema" {
+		t.Errorf("unexpected $schema %v", schema.Schema)
+	}
+	metrics := schema.Properties.Metrics
+	if len(metrics.Required) != 2 || metrics.Required[0] != "handling_time" || metrics.Required[1] != "lead_time" {
+		t.Errorf("expected the mandatory blocks to be required, got %v", metrics.Required)
+	}
+	if required := metrics.Properties["lead_time"].Required; len(required) != 1 || required[0] != "estimated_days" {
+		t.Errorf("expected LEAD_TIME.estimated_days to be required, got %v", required)
+	}
+
+	again, _ := ToJSONSchemaDraft(config, JSONSchemaDraft202012)
+	if string(again) != string(source) {
+		t.Errorf("expected a stable output")
+	}
+
+	if _, err := ToJSONSchemaDraft(config, "draft-04"); err == nil {
+		t.Errorf("expected an error for an unsupported draft")
+	}
+}
@@ -0,0 +1,307 @@
+package bic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Limits bounds the shape of a payload. A zero value disables the corresponding check.
+// Decoded from JSON, the keys left out keep their DefaultLimits value.
+type Limits struct {
+	MaxBytes        int `json:"max_bytes"`
+	MaxDepth        int `json:"max_depth"`
+	MaxKeys         int `json:"max_keys"`
+	MaxArrayLength  int `json:"max_array_length"`
+	MaxStringLength int `json:"max_string_length"`
+}
+
+// DefaultLimits are applied when the producer configuration does not declare its own.
+var DefaultLimits = Limits{
+	MaxBytes:        1 << 20,
+	MaxDepth:        32,
+	MaxKeys:         1000,
+	MaxArrayLength:  10000,
+	MaxStringLength: 64 << 10,
+}
+
+func (limits *Limits) UnmarshalJSON(data []byte) error {
+	type plain Limits
+	merged := plain(DefaultLimits)
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return err
+	}
+	*limits = Limits(merged)
+	return nil
+}
+
+// LimitError is returned when a payload exceeds one of the configured Limits.
+type LimitError struct {
+	Limit string
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("payload exceeds %v limit of %v", e.Limit, e.Max)
+}
+
+type limitFrame struct {
+	isObject bool
+	count    int
+}
+
+// contextCheckInterval is the number of tokens read between two checks of the context.
+const contextCheckInterval = 64
+
+// limitedDecoder wraps json.Decoder.Token and fails with a *LimitError as soon as
+// a token exceeds one of the limits, or with the context error once ctx is done.
+type limitedDecoder struct {
+	ctx       context.Context
+	decoder   *json.Decoder
+	limits    Limits
+	stack     []limitFrame
+	expectKey bool
+	tokens    int
+}
+
+func newLimitedDecoder(ctx context.Context, jsonBytes []byte, limits Limits) *limitedDecoder {
+	return &limitedDecoder{ctx: ctx, decoder: json.NewDecoder(bytes.NewReader(jsonBytes)), limits: limits}
+}
+
+func (d *limitedDecoder) Token() (json.Token, error) {
+	if d.tokens++; d.tokens%contextCheckInterval == 0 {
+		if err := d.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	token, err := d.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	limits := d.limits
+
+	if str, isString := token.(string); isString && limits.MaxStringLength > 0 && len(str) > limits.MaxStringLength {
+		return nil, &LimitError{Limit: "max_string_length", Max: limits.MaxStringLength}
+	}
+
+	if d.expectKey {
+		// Object keys are only counted, their value comes in the next token
+		frame := &d.stack[len(d.stack)-1]
+		frame.count++
+		if limits.MaxKeys > 0 && frame.count > limits.MaxKeys {
+			return nil, &LimitError{Limit: "max_keys", Max: limits.MaxKeys}
+		}
+		d.expectKey = false
+		return token, nil
+	}
+
+	if len(d.stack) > 0 && !d.stack[len(d.stack)-1].isObject {
+		if delim, isDelim := token.(json.Delim); !isDelim || delim == '{' || delim == '[' {
+			frame := &d.stack[len(d.stack)-1]
+			frame.count++
+			if limits.MaxArrayLength > 0 && frame.count > limits.MaxArrayLength {
+				return nil, &LimitError{Limit: "max_array_length", Max: limits.MaxArrayLength}
+			}
+		}
+	}
+
+	switch token {
+	case json.Delim('{'), json.Delim('['):
+		d.stack = append(d.stack, limitFrame{isObject: token == json.Delim('{')})
+		if limits.MaxDepth > 0 && len(d.stack) > limits.MaxDepth {
+			return nil, &LimitError{Limit: "max_depth", Max: limits.MaxDepth}
+		}
+	case json.Delim('}'), json.Delim(']'):
+		d.stack = d.stack[:len(d.stack)-1]
+	}
+
+	d.expectKey = len(d.stack) > 0 && d.stack[len(d.stack)-1].isObject && d.decoder.More()
+	return token, nil
+}
+
+// PayloadDecoder reads the tokens of a payload while enforcing its Limits. It is the
+// single pass of the validators generated by bicgen.
+type PayloadDecoder struct {
+	decoder *limitedDecoder
+	err     error
+}
+
+func NewPayloadDecoder(payloadContent []byte, limits Limits) *PayloadDecoder {
+	return NewPayloadDecoderContext(context.Background(), payloadContent, limits)
+}
+
+// NewPayloadDecoderContext returns a decoder whose Token fails with the context error
+// once ctx is done.
+func NewPayloadDecoderContext(ctx context.Context, payloadContent []byte, limits Limits) *PayloadDecoder {
+	decoder := &PayloadDecoder{decoder: newLimitedDecoder(ctx, payloadContent, limits)}
+	if limits.MaxBytes > 0 && len(payloadContent) > limits.MaxBytes {
+		decoder.err = &LimitError{Limit: "max_bytes", Max: limits.MaxBytes}
+	}
+	return decoder
+}
+
+// Token returns the next token, or the error that stopped the decoding.
+func (d *PayloadDecoder) Token() (json.Token, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	token, err := d.decoder.Token()
+	d.err = err
+	return token, err
+}
+
+// Value reads the rest of the value that starts with token, as json.Unmarshal would
+// into an interface{}.
+func (d *PayloadDecoder) Value(token json.Token) (interface{}, error) {
+	return decodeValue(d, token)
+}
+
+// Skip reads the rest of the value that starts with token, without decoding it.
+func (d *PayloadDecoder) Skip(token json.Token) error {
+	return skipValue(d, token)
+}
+
+// End fails when anything but white space follows the document.
+func (d *PayloadDecoder) End() error {
+	if _, err := d.Token(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return errTrailingData
+	}
+	return nil
+}
+
+// decodePayload unmarshals a payload in the same pass that enforces the limits. The
+// payloads json.Unmarshal rejects are parsed again by it, so its errors are kept. The
+// context error is returned as is once ctx is done.
+func decodePayload(ctx context.Context, jsonBytes []byte, limits Limits) (*StructPayload, error) {
+	if limits.MaxBytes > 0 && len(jsonBytes) > limits.MaxBytes {
+		return nil, &LimitError{Limit: "max_bytes", Max: limits.MaxBytes}
+	}
+
+	state := &streamScan{decoder: newLimitedDecoder(ctx, jsonBytes, limits)}
+	payload := new(StructPayload)
+	err := state.decodeDocument(payload)
+	if limitError, isLimit := err.(*LimitError); isLimit {
+		return nil, limitError
+	} else if err != nil && err == ctx.Err() {
+		return nil, err
+	}
+	if err != nil || state.typeError != nil {
+		payload = new(StructPayload)
+		if err := json.Unmarshal(jsonBytes, payload); err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
+
+// decodeDocument fills payload from the tokens, as json.Unmarshal would. Type mismatches
+// are recorded and skipped so the rest of the payload is still checked against the limits.
+func (state *streamScan) decodeDocument(payload *StructPayload) error {
+	token, err := state.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		if err := state.decodeEnvelope(payload); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		state.setTypeError(token, "", "StructPayload")
+		if err := state.skipValue(token); err != nil {
+			return err
+		}
+	}
+
+	if _, err := state.decoder.Token(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		for {
+			if _, err := state.decoder.Token(); err != nil {
+				if _, isLimit := err.(*LimitError); isLimit {
+					return err
+				}
+				break
+			}
+		}
+		return errTrailingData
+	}
+	return nil
+}
+
+func (state *streamScan) decodeEnvelope(payload *StructPayload) error {
+	for {
+		token, err := state.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token == json.Delim('}') {
+			return nil
+		}
+
+		key, _ := token.(string)
+		value, err := state.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case strings.EqualFold(key, "id"):
+			err = state.scanString(value, "id", &payload.ID)
+		case strings.EqualFold(key, "entity"):
+			err = state.scanString(value, "entity", &payload.Entity)
+		case strings.EqualFold(key, "ProducerToken"):
+			err = state.scanString(value, "ProducerToken", &payload.ProducerToken)
+		case strings.EqualFold(key, "metrics"):
+			err = state.decodeMetrics(value, payload)
+		default:
+			err = state.skipValue(value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// decodeMetrics merges the object into the metrics map, like json.Unmarshal does with
+// repeated keys.
+func (state *streamScan) decodeMetrics(value json.Token, payload *StructPayload) error {
+	switch value {
+	case nil:
+		payload.Metrics = nil
+		return nil
+	case json.Delim('{'):
+		if payload.Metrics == nil {
+			payload.Metrics = make(map[string]interface{})
+		}
+		for {
+			key, err := state.decoder.Token()
+			if err != nil {
+				return err
+			}
+			if key == json.Delim('}') {
+				return nil
+			}
+			next, err := state.decoder.Token()
+			if err != nil {
+				return err
+			}
+			metric, err := state.decodeValue(next)
+			if err != nil {
+				return err
+			}
+			payload.Metrics[key.(string)] = metric
+		}
+	}
+	state.setTypeError(value, "metrics", "map[string]interface {}")
+	return state.skipValue(value)
+}
@@ -0,0 +1,104 @@
+package bic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+)
+
+func TestGetPayloadBodyWithLimits(t *testing.T) {
+	limits := Limits{MaxBytes: 200, MaxDepth: 4, MaxKeys: 3, MaxArrayLength: 2, MaxStringLength: 7}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"within limits", `{"id": "1", "entity": "S", "metrics": {"a": [1, 2]}}`, 0},
+		{"too many bytes", `{"id": "1", "entity": "S", "metrics": {"a": "` + strings.Repeat("x", 200) + `"}}`, http.StatusRequestEntityTooLarge},
+		{"too deep", `{"id": "1", "entity": "S", "metrics": {"a": {"b": {"c": {}}}}}`, http.StatusUnprocessableEntity},
+		{"too many keys", `{"id": "1", "entity": "S", "metrics": {}, "version": "1"}`, http.StatusUnprocessableEntity},
+		{"array too long", `{"id": "1", "entity": "S", "metrics": {"a": [1, 2, 3]}}`, http.StatusUnprocessableEntity},
+		{"nested arrays count once", `{"id": "1", "entity": "S", "metrics": {"a": [[1, 2], [3]]}}`, 0},
+		{"string too long", `{"id": "1", "entity": "S", "metrics": {"a": "abcdefgh"}}`, http.StatusUnprocessableEntity},
+		{"key too long", `{"id": "1", "entity": "S", "metrics": {"abcdefgh": 1}}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		_, err := GetPayloadBodyWithLimits("1", []byte(test.body), limits)
+		if test.status == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected status %v, got no error", test.name, test.status)
+		} else if err.Status() != test.status {
+			t.Errorf("%s: expected status %v, got %v", test.name, test.status, err.Status())
+		}
+	}
+}
+
+func TestGetPayloadBodyKeepsSyntaxErrors(t *testing.T) {
+	_, err := GetPayloadBodyWithLimits("1", []byte(`{"id": `), DefaultLimits)
+	if err == nil || err.Status() != http.StatusInternalServerError {
+		t.Errorf("expected unmarshal error, got %v", err)
+	}
+}
+
+func TestDecodePayloadMatchesUnmarshal(t *testing.T) {
+	payloads := []string{
+		`{"id": "1", "entity": "S", "metrics": {"a": {"b": [1, "2", null]}}}`,
+		`{"ID": "1", "Entity": "S", "METRICS": {"a": 1}, "other": {"x": [1]}}`,
+		`{"id": "1", "metrics": {"a": 1}, "metrics": {"b": 2}}`,
+		`{"id": "1", "metrics": {"a": 1}, "metrics": null, "metrics": {"b": 2}}`,
+		`{"id": null, "entity": "S", "metrics": {}}`,
+		`{"id": 1, "entity": "S", "metrics": {}}`,
+		`{"id": "1", "metrics": [1]}`,
+		`{"id": "1", "ProducerToken": "x"}`,
+		`null`,
+		`[]`,
+		`{"id": "1"} {}`,
+		`{"id": `,
+		`{"id": "1", "metrics": {"a": 1e400}}`,
+	}
+
+	for _, payload := range payloads {
+		expected := new(StructPayload)
+		expectedError := json.Unmarshal([]byte(payload), expected)
+		decoded, err := decodePayload(context.Background(), []byte(payload), DefaultLimits)
+		if fmt.Sprint(err) != fmt.Sprint(expectedError) {
+			t.Errorf("%s: expected error %v, got %v", payload, expectedError, err)
+		} else if err == nil && !reflect.DeepEqual(decoded, expected) {
+			t.Errorf("%s: expected %+v, got %+v", payload, expected, decoded)
+		}
+	}
+}
+
+func TestPartialLimitsKeepDefaults(t *testing.T) {
+	config, err := GetProducerConfig("1", []byte(`{"id": "1", "status": "enabled", "limits": {"max_keys": 3, "max_depth": 0}}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := DefaultLimits
+	expected.MaxKeys, expected.MaxDepth = 3, 0
+	if *config.Limits != expected {
+		t.Errorf("expected %+v, got %+v", expected, *config.Limits)
+	}
+	if limits := Compile(config).Limits(); limits != expected {
+		t.Errorf("expected compiled limits %+v, got %+v", expected, limits)
+	}
+
+	body := `{"id": "1", "entity": "S", "metrics": {"a": "` + strings.Repeat("x", DefaultLimits.MaxStringLength+1) + `"}}`
+	if _, err := ValidateContext(context.Background(), []byte(body), config); err == nil || err.(apierrors.ApiError).Status() != http.StatusUnprocessableEntity {
+		t.Errorf("expected the default max_string_length, got %v", err)
+	}
+}
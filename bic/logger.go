@@ -0,0 +1,59 @@
+package bic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/logger"
+)
+
+// Field is a structured key/value attached to a log line.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger receives every log line written by the validator.
+type Logger interface {
+	Error(message string, err error, fields ...Field)
+	Debug(message string, fields ...Field)
+}
+
+// DefaultLogger is used by the package level functions and by validators without a
+// Logger of their own.
+var DefaultLogger Logger = ToolkitLogger{}
+
+// ToolkitLogger writes to the go-meli-toolkit global logger, rendering fields with the
+// "[key: value]" suffix used across the service.
+type ToolkitLogger struct{}
+
+func (ToolkitLogger) Error(message string, err error, fields ...Field) {
+	logger.Error(message+formatFields(fields), err)
+}
+
+func (ToolkitLogger) Debug(message string, fields ...Field) {
+	logger.Debug(message + formatFields(fields))
+}
+
+// NopLogger discards everything. Useful for benchmarks.
+type NopLogger struct{}
+
+func (NopLogger) Error(message string, err error, fields ...Field) {}
+
+func (NopLogger) Debug(message string, fields ...Field) {}
+
+func formatFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString(" ")
+	for _, field := range fields {
+		fmt.Fprintf(&builder, "[%v: %v]", field.Key, field.Value)
+	}
+	return builder.String()
+}
@@ -0,0 +1,96 @@
+package bic
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingLogger keeps every rendered log line.
+type recordingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (log *recordingLogger) Error(message string, err error, fields ...Field) {
+	log.record(fmt.Sprint(message, formatFields(fields), " ", err))
+}
+
+func (log *recordingLogger) Debug(message string, fields ...Field) {
+	log.record(message + formatFields(fields))
+}
+
+func (log *recordingLogger) record(line string) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	log.lines = append(log.lines, line)
+}
+
+// captureLogs installs a recordingLogger as DefaultLogger for the duration of a test.
+func captureLogs(t *testing.T) *recordingLogger {
+	log := new(recordingLogger)
+	previous := DefaultLogger
+	DefaultLogger = log
+	t.Cleanup(func() { DefaultLogger = previous })
+	return log
+}
+
+func TestValidatorUsesInjectedLogger(t *testing.T) {
+	defaultLog := captureLogs(t)
+
+	config, _ := GetProducerConfig("1", []byte(piiConfigJS))
+	log := new(recordingLogger)
+	validator := NewValidator(config, log)
+
+	valid, _ := validator.Validate([]byte(`{"id": "28", "entity": "SHIPMENT", "metrics": {"unknown": {"field": 1}}}`))
+	if valid {
+		t.Fatal("expected invalid payload")
+	}
+	if len(log.lines) == 0 {
+		t.Fatal("expected the injected logger to receive the validation errors")
+	}
+	if len(defaultLog.lines) != 0 {
+		t.Errorf("default logger should not be used, got %v", defaultLog.lines)
+	}
+
+	found := false
+	for _, line := range log.lines {
+		if strings.Contains(line, "Not acceptable provided metrics [id: 28][entity: SHIPMENT]") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected structured fields in %v", log.lines)
+	}
+}
+
+func TestValidatorWithoutLoggerUsesDefault(t *testing.T) {
+	defaultLog := captureLogs(t)
+
+	config, _ := GetProducerConfig("1", []byte(piiConfigJS))
+	validator := &Validator{Config: config}
+	validator.Validate([]byte(`{"id": "28", "entity": "OTHER", "metrics": {}}`))
+
+	if len(defaultLog.lines) == 0 {
+		t.Error("expected the default logger to be used")
+	}
+}
+
+func BenchmarkValidatorInjectedLogger(b *testing.B) {
+	config, err := GetProducerConfigFromFile("config-productor.json")
+	if err != nil {
+		b.Fatal("Error reading config " + err.Error())
+	}
+	payloadContent, err := ioutil.ReadFile("../document.json")
+	if err != nil {
+		b.Fatal("Error reading document file")
+	}
+
+	validator := NewValidator(config, NopLogger{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validator.Validate(payloadContent)
+	}
+}
@@ -0,0 +1,76 @@
+package bic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Redaction controls what the validator is allowed to write to the logs.
+type Redaction struct {
+	HashTokens    bool
+	HashIDs       bool
+	DigestBodies  bool
+	MaxBodyLength int
+}
+
+// LogRedaction is applied to every log line written by the bic package. Raw bodies
+// are replaced by a digest unless DigestBodies is disabled, in which case they are
+// truncated to MaxBodyLength bytes.
+var LogRedaction = Redaction{
+	HashTokens:    true,
+	DigestBodies:  true,
+	MaxBodyLength: 256,
+}
+
+const maskedValue = "***"
+
+func digest(value []byte) string {
+	sum := sha256.Sum256(value)
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+func redactToken(token string) string {
+	if !LogRedaction.HashTokens || token == "" {
+		return token
+	}
+	return digest([]byte(token))
+}
+
+func redactID(id string) string {
+	if !LogRedaction.HashIDs || id == "" {
+		return id
+	}
+	return digest([]byte(id))
+}
+
+func redactBody(body []byte) string {
+	if LogRedaction.DigestBodies {
+		return fmt.Sprintf("%v (%v bytes)", digest(body), len(body))
+	}
+	if LogRedaction.MaxBodyLength >= 0 && len(body) > LogRedaction.MaxBodyLength {
+		return string(body[:LogRedaction.MaxBodyLength]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+// piiFieldSet indexes the metric paths flagged as PII. Paths are matched case
+// insensitively, like mandatory fields.
+func piiFieldSet(piiFields *[]string) map[string]bool {
+	if piiFields == nil {
+		return nil
+	}
+	set := make(map[string]bool, len(*piiFields))
+	for _, field := range *piiFields {
+		set[strings.ToLower(field)] = true
+	}
+	return set
+}
+
+func isPIIPath(piiFields map[string]bool, path []string) bool {
+	if len(piiFields) == 0 {
+		return false
+	}
+	return piiFields[strings.ToLower(strings.Join(path, "."))]
+}
@@ -0,0 +1,93 @@
+package bic
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const (
+	piiSecret   = "4509-9535-6623-3704"
+	piiToken    = "producer-secret-token"
+	piiConfigJS = `{
+	"id": "3",
+	"entity": "SHIPMENT",
+	"status": "enabled",
+	"allowed_metrics": {"buyer": {"card": "number", "doc": "number"}},
+	"pii_fields": ["buyer.card", "BUYER.DOC"]
+}`
+)
+
+func TestPIINeverReachesLogger(t *testing.T) {
+	log := captureLogs(t)
+
+	config, err := GetProducerConfig(piiToken, []byte(piiConfigJS))
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+
+	payloads := []string{
+		`{"id": "1", "entity": "SHIPMENT", "metrics": {"buyer": {"card": "` + piiSecret + `"}}}`,
+		`{"id": "1", "entity": "SHIPMENT", "metrics": {"buyer": {"doc": "` + piiSecret + `"}}}`,
+		`{"id": "1", "entity": "OTHER", "metrics": {"buyer": {"card": "` + piiSecret + `"}}}`,
+		`{"id": "1", "entity": "SHIPMENT", "metrics": {"buyer": {"card": "` + piiSecret + `"}`,
+		`{"id": "", "entity": "SHIPMENT", "metrics": {"buyer": {"card": "` + piiSecret + `"}}}`,
+	}
+
+	for _, payload := range payloads {
+		parsed, parseError := getPayloadBody(context.Background(), log, piiToken, []byte(payload), DefaultLimits)
+		if parseError != nil {
+			continue
+		}
+		if _, validationError := validatePayloadBody(log, piiToken, parsed); validationError != nil {
+			continue
+		}
+		if _, validationError := validatePayload(context.Background(), log, parsed, config); validationError != nil {
+			if strings.Contains(validationError.Error(), piiSecret) {
+				t.Errorf("PII value returned in error: %v", validationError)
+			}
+		}
+	}
+
+	GetProducerConfig(piiToken, []byte(`{"id": "`+piiSecret+`"`))
+
+	if len(log.lines) == 0 {
+		t.Fatal("expected validation failures to be logged")
+	}
+	for _, line := range log.lines {
+		if strings.Contains(line, piiSecret) {
+			t.Errorf("PII value reached the logger: %v", line)
+		}
+		if strings.Contains(line, piiToken) {
+			t.Errorf("producer token reached the logger: %v", line)
+		}
+	}
+}
+
+func TestNonPIIValuesAreStillReported(t *testing.T) {
+	log := captureLogs(t)
+
+	config, _ := GetProducerConfig("1", []byte(piiConfigJS))
+	config.AllowedMetrics["seller"] = map[string]interface{}{"score": "number"}
+
+	payload, _ := GetPayloadBody("1", []byte(`{"id": "1", "entity": "SHIPMENT", "metrics": {"seller": {"score": "high"}}}`))
+	_, err := validatePayload(context.Background(), log, payload, config)
+	if err == nil || !strings.Contains(err.Error(), "high") {
+		t.Errorf("expected sent value in error, got %v", err)
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	previous := LogRedaction
+	defer func() { LogRedaction = previous }()
+
+	body := []byte(strings.Repeat("a", 10))
+	if redacted := redactBody(body); strings.Contains(redacted, "aaaa") {
+		t.Errorf("digest should not contain the body, got %v", redacted)
+	}
+
+	LogRedaction = Redaction{MaxBodyLength: 4}
+	if redacted := redactBody(body); redacted != "aaaa...(truncated)" {
+		t.Errorf("unexpected truncated body %v", redacted)
+	}
+}
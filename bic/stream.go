@@ -0,0 +1,444 @@
+package bic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+	"github.com/mercadolibre/jsonschema_test/stopwatch"
+)
+
+var errTrailingData = errors.New("invalid character after top-level value")
+
+// StreamValidator validates payloads in a single pass over their JSON tokens. It checks
+// the envelope, the metric paths and the leaf types against a compiled configuration
+// without building the metrics map, and reports the same errors as Validate but for:
+//   - repeated metric keys: json.Unmarshal keeps the last value and Validate only checks
+//     it, while every value is checked here, a non null one meeting a mandatory field.
+//   - mandatory fields of blocks nested in blocks: past a nested block, flattenMetricsMap
+//     drops the start of the paths, so Validate can miss the fields depending on the map
+//     order. Here the full paths are matched.
+type StreamValidator struct {
+	Config *CompiledConfig
+	Logger Logger
+}
+
+func NewStreamValidator(config *StructProducerConfig, log Logger) *StreamValidator {
+	return &StreamValidator{Config: Compile(config), Logger: log}
+}
+
+func (validator *StreamValidator) logger() Logger {
+	if validator.Logger == nil {
+		return DefaultLogger
+	}
+	return validator.Logger
+}
+
+func (validator *StreamValidator) Validate(payloadContent []byte) (bool, error) {
+	return validator.ValidateContext(context.Background(), payloadContent)
+}
+
+func (validator *StreamValidator) ValidateContext(ctx context.Context, payloadContent []byte) (bool, error) {
+	defer stopwatch.Track(ctx)()
+
+	if err := validator.scan(ctx, payloadContent); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Decode validates the payload and, only when it is valid, unmarshals it.
+func (validator *StreamValidator) Decode(payloadContent []byte) (*StructPayload, error) {
+	if err := validator.scan(context.Background(), payloadContent); err != nil {
+		return nil, err
+	}
+
+	payload := new(StructPayload)
+	if err := json.Unmarshal(payloadContent, payload); err != nil {
+		return nil, apierrors.NewInternalServerApiError("error unmarshalling body from feed into struct payload", err)
+	}
+	payload.ProducerToken = "1"
+	return payload, nil
+}
+
+// streamScan holds the state collected while walking the tokens of one payload.
+type streamScan struct {
+	ctx     context.Context
+	log     Logger
+	config  *CompiledConfig
+	decoder *limitedDecoder
+
+	id, entity     string
+	entityIndex    int
+	entityResolved bool
+	metricsPresent bool
+	typeError      error
+
+	path         []string
+	leaves       map[string]bool //Non null leaf paths, only collected when mandatory fields are configured
+	metricErrors []error
+}
+
+func (validator *StreamValidator) scan(ctx context.Context, payloadContent []byte) apierrors.ApiError {
+	_, err := validator.scanState(ctx, payloadContent)
+	return err
+}
+
+// scanState validates the payload and also returns the scan state, so callers can read
+// the envelope fields collected before any error.
+func (validator *StreamValidator) scanState(ctx context.Context, payloadContent []byte) (*streamScan, apierrors.ApiError) {
+	log := validator.logger()
+	config := validator.Config
+
+	state := &streamScan{
+		ctx:          ctx,
+		log:          log,
+		config:       config,
+		decoder:      newLimitedDecoder(ctx, payloadContent, config.limits),
+		entityIndex:  -1,
+		metricErrors: make([]error, len(config.entities)),
+	}
+
+	if ctx.Err() != nil {
+		return state, NewContextApiError(ctx.Err())
+	}
+
+	if config.limits.MaxBytes > 0 && len(payloadContent) > config.limits.MaxBytes {
+		limitError := &LimitError{Limit: "max_bytes", Max: config.limits.MaxBytes}
+		log.Error("Payload exceeds limits", limitError)
+		return state, NewLimitApiError(limitError)
+	}
+
+	if config.mandatoryLeaves {
+		state.leaves = make(map[string]bool)
+	}
+
+	if err := state.scanDocument(); err != nil {
+		switch err := err.(type) {
+		case *LimitError:
+			log.Error("Payload exceeds limits", err)
+			return state, NewLimitApiError(err)
+		}
+		if err == ctx.Err() {
+			return state, NewContextApiError(err)
+		}
+		log.Error("Error unmarshalling body from feed into struct payload", err, F("body", redactBody(payloadContent)))
+		return state, apierrors.NewInternalServerApiError("error unmarshalling body from feed into struct payload", err)
+	}
+
+	return state, state.finish(payloadContent)
+}
+
+// finish reports the collected state in the same order Validate checks it.
+func (state *streamScan) finish(payloadContent []byte) apierrors.ApiError {
+	if state.typeError != nil {
+		state.log.Error("Error unmarshalling body from feed into struct payload", state.typeError, F("body", redactBody(payloadContent)))
+		return apierrors.NewInternalServerApiError("error unmarshalling body from feed into struct payload", state.typeError)
+	}
+
+	payload := &StructPayload{ID: state.id, Entity: state.entity}
+	if state.metricsPresent {
+		payload.Metrics = map[string]interface{}{}
+	}
+	if _, err := validatePayloadBody(state.log, "1", payload); err != nil {
+		return err
+	}
+
+	entityConfig, err := checkEntityAndStatus(state.log, payload, state.config.Config)
+	if err != nil {
+		return err
+	}
+
+	if entityConfig.MandatoryFields != nil {
+		if mandatoryFieldsError := checkMandatoryLeaves(entityConfig.MandatoryFields, state.leaves); mandatoryFieldsError != nil {
+			return newMandatoryFieldsApiError(state.log, payload, entityConfig, mandatoryFieldsError)
+		}
+	}
+
+	if metricError := state.metricErrors[state.config.findEntity(state.entity)]; metricError != nil {
+		return newMetricsApiError(state.ctx, state.log, payload, metricError)
+	}
+	return nil
+}
+
+func (state *streamScan) scanDocument() error {
+	token, err := state.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		if err := state.scanEnvelope(); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		state.setTypeError(token, "", "StructPayload")
+		if err := state.skipValue(token); err != nil {
+			return err
+		}
+	}
+
+	if _, err := state.decoder.Token(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		// Trailing values are still checked against the limits, like decodePayload does
+		for {
+			if _, err := state.decoder.Token(); err != nil {
+				if _, isLimit := err.(*LimitError); isLimit {
+					return err
+				}
+				break
+			}
+		}
+		return errTrailingData
+	}
+	return nil
+}
+
+func (state *streamScan) scanEnvelope() error {
+	for {
+		token, err := state.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token == json.Delim('}') {
+			return nil
+		}
+
+		key, _ := token.(string)
+		value, err := state.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case strings.EqualFold(key, "id"):
+			err = state.scanString(value, "id", &state.id)
+		case strings.EqualFold(key, "entity"):
+			err = state.scanString(value, "entity", &state.entity)
+		case strings.EqualFold(key, "ProducerToken"):
+			var ignored string
+			err = state.scanString(value, "ProducerToken", &ignored)
+		case strings.EqualFold(key, "metrics"):
+			err = state.scanMetrics(value)
+		default:
+			err = state.skipValue(value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (state *streamScan) scanString(value json.Token, field string, target *string) error {
+	switch value := value.(type) {
+	case string:
+		*target = value
+		if field == "entity" && state.config != nil {
+			state.entityIndex = state.config.findEntity(value)
+			state.entityResolved = true
+		}
+		return nil
+	case nil:
+		return nil
+	}
+	state.setTypeError(value, field, "string")
+	return state.skipValue(value)
+}
+
+func (state *streamScan) scanMetrics(value json.Token) error {
+	switch value {
+	case nil:
+		state.metricsPresent = false
+		return nil
+	case json.Delim('{'):
+		state.metricsPresent = true
+		return state.scanMetricObject()
+	}
+	state.setTypeError(value, "metrics", "map[string]interface {}")
+	return state.skipValue(value)
+}
+
+// scanMetricObject walks the object whose '{' was just read, checking every leaf.
+func (state *streamScan) scanMetricObject() error {
+	for {
+		token, err := state.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token == json.Delim('}') {
+			return nil
+		}
+		if err := state.ctx.Err(); err != nil { //Stops walking the payload once the request is done
+			return err
+		}
+
+		key, _ := token.(string)
+		value, err := state.decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		state.path = append(state.path, key)
+		switch value {
+		case json.Delim('{'):
+			err = state.scanMetricObject()
+		case json.Delim('['):
+			var array interface{}
+			if array, err = state.decodeValue(value); err == nil {
+				state.checkLeaf(array)
+			}
+		default:
+			state.checkLeaf(value)
+		}
+		state.path = state.path[:len(state.path)-1]
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// checkLeaf records the leaf for mandatory fields and validates it against the
+// candidate entities. Top level leaves are not validated, like in validateMetricBlock.
+func (state *streamScan) checkLeaf(value interface{}) {
+	if value != nil && state.config.mandatoryLeaves {
+		state.leaves[strings.Join(state.path, ".")] = true
+	}
+	if len(state.path) < 2 {
+		return
+	}
+
+	for i := range state.config.entities {
+		if state.entityResolved && i != state.entityIndex {
+			continue
+		}
+		if state.metricErrors[i] != nil {
+			continue
+		}
+		if pathError, err := state.config.entities[i].checkLeaf(state.log, state.path, value); err != nil {
+			state.metricErrors[i] = metricPathError(pathError, err)
+		}
+	}
+}
+
+func (state *streamScan) decodeValue(token json.Token) (interface{}, error) {
+	return decodeValue(state.decoder, token)
+}
+
+// decodeValue builds the generic value that starts with token, as json.Unmarshal would
+// into an interface{}.
+func decodeValue(decoder interface{ Token() (json.Token, error) }, token json.Token) (interface{}, error) {
+	switch token {
+	case json.Delim('{'):
+		object := make(map[string]interface{})
+		for {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			if key == json.Delim('}') {
+				return object, nil
+			}
+			next, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeValue(decoder, next)
+			if err != nil {
+				return nil, err
+			}
+			object[key.(string)] = value
+		}
+	case json.Delim('['):
+		array := make([]interface{}, 0)
+		for {
+			next, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			if next == json.Delim(']') {
+				return array, nil
+			}
+			value, err := decodeValue(decoder, next)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	}
+	return token, nil
+}
+
+func (state *streamScan) skipValue(token json.Token) error {
+	return skipValue(state.decoder, token)
+}
+
+// skipValue reads the rest of the value that starts with token, without decoding it.
+func skipValue(decoder interface{ Token() (json.Token, error) }, token json.Token) error {
+	if token != json.Delim('{') && token != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		next, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch next {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// setTypeError keeps the first type mismatch, json.Unmarshal reports the first one too.
+func (state *streamScan) setTypeError(token json.Token, field string, goType string) {
+	if state.typeError != nil {
+		return
+	}
+	kind := "number"
+	switch token.(type) {
+	case string:
+		kind = "string"
+	case bool:
+		kind = "bool"
+	case json.Delim:
+		kind = "object"
+		if token == json.Delim('[') {
+			kind = "array"
+		}
+	}
+	if field == "" {
+		state.typeError = fmt.Errorf("json: cannot unmarshal %v into Go value of type bic.%v", kind, goType)
+		return
+	}
+	state.typeError = fmt.Errorf("json: cannot unmarshal %v into Go struct field StructPayload.%v of type %v", kind, field, goType)
+}
+
+// checkMandatoryLeaves applies checkMandatoryFields over the non null leaf paths.
+func checkMandatoryLeaves(mandatoryFields *[]string, leaves map[string]bool) error {
+	validatorMap := make(map[string]bool)
+	for _, mandatoryFieldPath := range *mandatoryFields {
+		validated := false
+		for path := range leaves {
+			if !validatorMap[path] && strings.EqualFold(mandatoryFieldPath, path) {
+				validated = true
+				validatorMap[path] = true
+				break
+			}
+		}
+		if !validated {
+			return fmt.Errorf("missing mandatory field: %v", mandatoryFieldPath)
+		}
+	}
+	return nil
+}
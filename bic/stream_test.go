@@ -0,0 +1,197 @@
+package bic
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+)
+
+var mandatoryConfig = []byte(`{
+	"id": "4",
+	"entity": "SHIPMENT_TEST",
+	"status": "enabled",
+	"allowed_metrics": {
+		"handling_time": {"date_from": "datetime", "estimated_days": "number", "flags": "array"},
+		"lead_time": {"estimated_days": "number", "is_late": "boolean_number", "nested": {"level": "string"}},
+		"empty": {}
+	},
+	"mandatory_fields": ["handling_time.estimated_days", "LEAD_TIME.estimated_days"]
+}`)
+
+// streamCorpus is designed around mandatoryConfig: against it every payload has at most
+// one metric error, so both validators report exactly the same cause regardless of map
+// iteration order.
+var streamCorpus = []string{
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"date_from": "2019-10-11T13:38:29-03:00", "estimated_days": 1, "estimated_working_days": 3}, "lead_time": {"estimated_days": 3}}, "version": "0.0.1"}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "shipment_test", "id": "1", "metrics": {"handling_time": {"estimated_days": "1"}, "lead_time": {"estimated_days": 3}}}`,
+	`{"metrics": {"handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}, "id": "1", "entity": "SHIPMENT_TEST"}`,
+	`{"metrics": {"handling_time": {"unknown": 1}}, "id": "1", "entity": "SHIPMENT_TEST"}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": {"deeper": 1}}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"nested": 1}, "handling_time": {"estimated_days": 1}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"estimated_days": 3, "nested": {"level": 5}}, "handling_time": {"estimated_days": 1}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"empty": {"anything": 1}, "handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"unknown_block": {"anything": null}, "handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"top_level_leaf": 5, "handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1, "flags": [1, {"a": 2}]}, "lead_time": {"estimated_days": 3, "is_late": 1}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1, "flags": "no"}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3, "is_late": 2}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1, "date_from": "yesterday"}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": null}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": null}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1"}`,
+	`{"entity": "SHIPMENT_TEST", "id": "", "metrics": {}}`,
+	`{"entity": "", "id": "", "metrics": {}}`,
+	`{"entity": "OTHER", "id": "1", "metrics": {"handling_time": {"unknown": 1}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": 1, "metrics": {}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": []}`,
+	`{"ENTITY": "SHIPMENT_TEST", "Id": "1", "Metrics": {"handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1}}`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {}} {}`,
+	`[1, 2]`,
+	`null`,
+	`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": 1}, "lead_time": {"estimated_days": 3}}, "extra": [[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[1]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]}`,
+}
+
+func TestStreamValidatorMatchesValidate(t *testing.T) {
+	captureLogs(t)
+
+	productorConfig, err := GetProducerConfigFromFile("config-productor.json")
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+	configs := map[string]*StructProducerConfig{"config-productor.json": productorConfig}
+	for name, content := range map[string][]byte{"mandatory": mandatoryConfig, "multi entity": multiEntityConfig, "pii": []byte(piiConfigJS)} {
+		config, err := GetProducerConfig("1", content)
+		if err != nil {
+			t.Fatalf("Error reading config %v", err)
+		}
+		configs[name] = config
+	}
+
+	document, _ := ioutil.ReadFile("../document.json")
+	corpus := append([]string{string(document)}, streamCorpus...)
+
+	for name, config := range configs {
+		streamValidator := NewStreamValidator(config, nil)
+		for i, payload := range corpus {
+			exactCause := name == "mandatory" && i > 0
+			expectedValid, expectedError := Validate([]byte(payload), config)
+			valid, err := streamValidator.Validate([]byte(payload))
+
+			if valid != expectedValid {
+				t.Errorf("%s: %s: expected valid=%v (%v), got %v (%v)", name, payload, expectedValid, expectedError, valid, err)
+				continue
+			}
+			if expectedError == nil {
+				continue
+			}
+			expectedApiError := expectedError.(apierrors.ApiError)
+			apiError, isApiError := err.(apierrors.ApiError)
+			if !isApiError || apiError.Code() != expectedApiError.Code() || apiError.Status() != expectedApiError.Status() || apiError.Message() != expectedApiError.Message() {
+				t.Errorf("%s: %s: expected %v, got %v", name, payload, expectedError, err)
+				continue
+			}
+			if exactCause && expectedApiError.Status() != 500 && apiError.Error() != expectedApiError.Error() {
+				t.Errorf("%s: %s: expected %v, got %v", name, payload, expectedError, err)
+			}
+		}
+	}
+}
+
+// TestStreamValidatorDifferences pins the payloads where StreamValidator and Validate
+// disagree, as documented on StreamValidator.
+func TestStreamValidatorDifferences(t *testing.T) {
+	captureLogs(t)
+	config, err := GetProducerConfig("1", []byte(`{
+		"entity": "SHIPMENT_TEST",
+		"status": "enabled",
+		"allowed_metrics": {"handling_time": {"estimated_days": "number"}, "lead_time": {"window": {"range": {"days": "number"}, "offset_days": "number"}}},
+		"mandatory_fields": ["lead_time.window.offset_days"]
+	}`))
+	if err != nil {
+		t.Fatalf("Error reading config %v", err)
+	}
+	streamValidator := NewStreamValidator(config, nil)
+
+	tests := []struct {
+		payload     string
+		streamValid bool
+		valid       bool
+	}{
+		//Every value of a repeated key is checked, Validate only sees the last one
+		{`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"handling_time": {"estimated_days": "1", "estimated_days": 1}, "lead_time": {"window": {"offset_days": 2}}}}`, false, true},
+		{`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"window": {"offset_days": 2, "offset_days": null}}}}`, true, false},
+	}
+	for _, test := range tests {
+		if valid, err := streamValidator.Validate([]byte(test.payload)); valid != test.streamValid {
+			t.Errorf("%s: expected the stream to return %v, got %v (%v)", test.payload, test.streamValid, valid, err)
+		}
+		if valid, err := Validate([]byte(test.payload), config); valid != test.valid {
+			t.Errorf("%s: expected Validate to return %v, got %v (%v)", test.payload, test.valid, valid, err)
+		}
+	}
+
+	//Validate depends on the map order here, the stream always finds the field
+	nested := []byte(`{"entity": "SHIPMENT_TEST", "id": "1", "metrics": {"lead_time": {"window": {"range": {"days": 1}, "offset_days": 2}}}}`)
+	for i := 0; i < 10; i++ {
+		if valid, err := streamValidator.Validate(nested); !valid {
+			t.Fatalf("expected the nested mandatory field to be found, got %v", err)
+		}
+	}
+}
+
+func TestStreamValidatorDecode(t *testing.T) {
+	captureLogs(t)
+
+	config, _ := GetProducerConfig("1", mandatoryConfig)
+	validator := NewStreamValidator(config, nil)
+
+	payload, err := validator.Decode([]byte(streamCorpus[1]))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if payload.ID != "1" || payload.Metrics["lead_time"] == nil {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	if payload, err := validator.Decode([]byte(streamCorpus[2])); payload != nil || err == nil {
+		t.Errorf("invalid payloads should not be decoded, got %+v", payload)
+	}
+}
+
+func TestStreamValidatorMasksPII(t *testing.T) {
+	log := captureLogs(t)
+
+	config, _ := GetProducerConfig(piiToken, []byte(piiConfigJS))
+	_, err := NewStreamValidator(config, nil).Validate([]byte(`{"id": "1", "entity": "SHIPMENT", "metrics": {"buyer": {"card": "` + piiSecret + `"}}}`))
+	if err == nil || strings.Contains(err.Error(), piiSecret) {
+		t.Errorf("expected masked error, got %v", err)
+	}
+	for _, line := range log.lines {
+		if strings.Contains(line, piiSecret) {
+			t.Errorf("PII value reached the logger: %v", line)
+		}
+	}
+}
+
+func BenchmarkStreamValidator(b *testing.B) {
+	config, err := GetProducerConfigFromFile("config-productor.json")
+	if err != nil {
+		b.Fatal("Error reading config " + err.Error())
+	}
+	payloadContent, err := ioutil.ReadFile("../document.json")
+	if err != nil {
+		b.Fatal("Error reading document file")
+	}
+
+	validator := NewStreamValidator(config, NopLogger{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		validator.Validate(payloadContent)
+	}
+}
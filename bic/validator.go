@@ -0,0 +1,28 @@
+package bic
+
+import "context"
+
+// Validator validates payloads against a single producer configuration.
+type Validator struct {
+	Config *StructProducerConfig
+	Logger Logger
+}
+
+func NewValidator(config *StructProducerConfig, log Logger) *Validator {
+	return &Validator{Config: config, Logger: log}
+}
+
+func (validator *Validator) logger() Logger {
+	if validator.Logger == nil {
+		return DefaultLogger
+	}
+	return validator.Logger
+}
+
+func (validator *Validator) Validate(payloadContent []byte) (bool, error) {
+	return validate(context.Background(), validator.logger(), payloadContent, validator.Config)
+}
+
+func (validator *Validator) ValidateContext(ctx context.Context, payloadContent []byte) (bool, error) {
+	return validate(ctx, validator.logger(), payloadContent, validator.Config)
+}
@@ -0,0 +1,213 @@
+// Command bicbatch validates newline-delimited payloads against a bic producer
+// configuration, or a JSON Schema with -engine jschema. It prints one JSON result per
+// line, in input order whatever the number of workers, followed by a summary.
+//
+//	bicbatch -config bic/config-productor.json -in batch.jsonl -valid ok.jsonl -invalid ko.jsonl -workers 8
+//	bicbatch -engine jschema -schema file://./jschema/schema.json -in batch.jsonl
+//
+// With -shadow every line is also validated by a second engine, and the lines where
+// both engines disagree are written to the -report file. The output and the exit code
+// only depend on the primary engine.
+//
+//	bicbatch -engine bic -shadow jschema -report disagreements.jsonl -in batch.jsonl
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/mercadolibre/jsonschema_test/batch"
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/mercadolibre/jsonschema_test/validation"
+)
+
+func main() {
+	engine := flag.String("engine", validation.EngineStream, "validation engine: bic, stream or jschema")
+	configPath := flag.String("config", "./bic/config-productor.json", "producer configuration file")
+	schemaURI := flag.String("schema", "file://./jschema/schema.json", "JSON Schema used by the jschema engine")
+	in := flag.String("in", "", "newline-delimited payloads, stdin when empty")
+	validPath := flag.String("valid", "", "file receiving the valid lines")
+	invalidPath := flag.String("invalid", "", "file receiving the invalid lines")
+	maxLineBytes := flag.Int("max-line-bytes", -1, "longer lines are reported as payload_too_large, only buffered for -invalid, 0 disables it and -1 takes the max_bytes limit of the engines")
+	shadowEngine := flag.String("shadow", "", "engine validating every line in shadow mode, disabled when empty")
+	reportPath := flag.String("report", "disagreements.jsonl", "file receiving the shadow mode disagreements")
+	maxPending := flag.Int("shadow-pending", validation.DefaultMaxPending, "shadow validations running at once, past them lines are not shadowed")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of lines validated concurrently")
+	flag.Parse()
+
+	bic.DefaultLogger = bic.NopLogger{}
+	validator, err := validation.Load(*engine, *configPath, *schemaURI)
+	if err != nil {
+		exit(err)
+	}
+
+	var report *validation.Report
+	var shadow *validation.Shadow
+	if *shadowEngine != "" {
+		shadowValidator, err := validation.Load(*shadowEngine, *configPath, *schemaURI)
+		if err != nil {
+			exit(err)
+		}
+		if report, err = validation.CreateReport(*reportPath); err != nil {
+			exit(err)
+		}
+		shadow = validation.NewShadow(
+			validation.Engine{Name: *engine, Validator: validator},
+			validation.Engine{Name: *shadowEngine, Validator: shadowValidator},
+			report,
+		)
+		shadow.MaxPending = *maxPending
+		validator = shadow
+	}
+
+	var input io.Reader = os.Stdin
+	if *in != "" {
+		file, err := os.Open(*in)
+		if err != nil {
+			exit(err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	validOutput, err := createOutput(*validPath)
+	if err != nil {
+		exit(err)
+	}
+	invalidOutput, err := createOutput(*invalidPath)
+	if err != nil {
+		exit(err)
+	}
+
+	if *maxLineBytes < 0 {
+		if *maxLineBytes, err = maxBytes(*configPath, *engine, *shadowEngine); err != nil {
+			exit(err)
+		}
+	}
+
+	pool := batch.NewPool(validator, *workers)
+	pool.MaxLineBytes = *maxLineBytes
+	pool.KeepLongLines = invalidOutput != nil
+
+	output := bufio.NewWriter(os.Stdout)
+	encoder := json.NewEncoder(output)
+	summary := bic.NewBatchSummary()
+	err = pool.Run(context.Background(), input, func(line batch.Result) error {
+		result := bic.LineResult{
+			Line:    line.Line,
+			Valid:   line.Valid,
+			Code:    line.Code,
+			Status:  line.Status,
+			Message: line.Message,
+			Errors:  line.Errors,
+		}
+		result.ID, result.Entity = bic.PeekEnvelope(line.Content)
+		summary.Add(result)
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+
+		if result.Valid {
+			return validOutput.writeLine(line.Content)
+		}
+		return invalidOutput.writeLine(line.Content)
+	})
+	if err != nil {
+		exit(err)
+	}
+
+	if err := encoder.Encode(map[string]bic.BatchSummary{"summary": summary}); err != nil {
+		exit(err)
+	}
+	if err := output.Flush(); err != nil {
+		exit(err)
+	}
+	for _, target := range []*lineOutput{validOutput, invalidOutput} {
+		if err := target.close(); err != nil {
+			exit(err)
+		}
+	}
+
+	if report != nil {
+		if err := shadow.Wait(); err != nil {
+			exit(err)
+		}
+		if err := report.Close(); err != nil {
+			exit(err)
+		}
+		fmt.Fprintf(os.Stderr, "bicbatch: %v disagreements written to %v, %v lines not shadowed\n", report.Count(), *reportPath, shadow.Dropped())
+	}
+
+	if summary.Invalid > 0 {
+		os.Exit(1)
+	}
+}
+
+// maxBytes returns the max_bytes limit of the config when an engine validates with it,
+// the one of bic.DefaultLimits, enforced by jschema, otherwise.
+func maxBytes(configPath string, engines ...string) (int, error) {
+	for _, engine := range engines {
+		if engine != validation.EngineBic && engine != validation.EngineStream {
+			continue
+		}
+		config, err := bic.GetProducerConfigFromFile(configPath)
+		if err != nil {
+			return 0, err
+		}
+		if config.Limits != nil {
+			return config.Limits.MaxBytes, nil
+		}
+		break
+	}
+	return bic.DefaultLimits.MaxBytes, nil
+}
+
+// lineOutput is a file receiving the lines of one verdict, nil when its flag is empty.
+type lineOutput struct {
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func createOutput(path string) (*lineOutput, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &lineOutput{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+func (output *lineOutput) writeLine(content []byte) error {
+	if output == nil {
+		return nil
+	}
+	if _, err := output.writer.Write(content); err != nil {
+		return err
+	}
+	return output.writer.WriteByte('\n')
+}
+
+// close flushes the lines and closes the file, returning the first error.
+func (output *lineOutput) close() error {
+	if output == nil {
+		return nil
+	}
+	err := output.writer.Flush()
+	if closeError := output.file.Close(); err == nil {
+		err = closeError
+	}
+	return err
+}
+
+func exit(err error) {
+	fmt.Fprintf(os.Stderr, "bicbatch: %v\n", err)
+	os.Exit(2)
+}
@@ -0,0 +1,48 @@
+// Command bicgen generates a specialized Go validator from a bic producer configuration.
+//
+//	bicgen -config bic/config-productor.json -package shipmenttest -out validator.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/mercadolibre/jsonschema_test/bic/gen"
+)
+
+func main() {
+	configPath := flag.String("config", "./bic/config-productor.json", "producer configuration file")
+	packageName := flag.String("package", "", "package name of the generated file")
+	out := flag.String("out", "", "output file, stdout when empty")
+	flag.Parse()
+
+	if *packageName == "" {
+		fmt.Fprintln(os.Stderr, "bicgen: -package is required")
+		os.Exit(2)
+	}
+
+	config, err := bic.GetProducerConfigFromFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bicgen: error reading config %v\n", err)
+		os.Exit(1)
+	}
+
+	source, err := gen.Generate(config, *packageName, filepath.Base(*configPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bicgen: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(source)
+		return
+	}
+	if err := ioutil.WriteFile(*out, source, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "bicgen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,63 @@
+// Command bicimport converts a JSON Schema into a bic producer configuration. Keywords
+// the configuration can't represent are listed on stderr.
+//
+//	bicimport -schema jschema/schema.json -entity SHIPMENT_TEST -out config.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "./jschema/schema.json", "JSON Schema file")
+	entity := flag.String("entity", "", "entity of the config, when the schema doesn't constrain it")
+	out := flag.String("out", "", "output file, stdout when empty")
+	strict := flag.Bool("strict", false, "exit with status 1 when some keyword can't be represented")
+	flag.Parse()
+
+	schema, err := ioutil.ReadFile(*schemaPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bicimport: %v\n", err)
+		os.Exit(1)
+	}
+
+	config, issues, err := bic.FromJSONSchema(schema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bicimport: %v\n", err)
+		os.Exit(1)
+	}
+	var unresolved []bic.SchemaIssue
+	for _, issue := range issues {
+		if *entity != "" && issue.Path == "/properties/entity" {
+			continue //The flag sets the entity
+		}
+		fmt.Fprintf(os.Stderr, "bicimport: %v\n", issue)
+		unresolved = append(unresolved, issue)
+	}
+	if *entity != "" && len(config.Entities) == 0 {
+		config.Entity = *entity
+	}
+
+	content, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bicimport: %v\n", err)
+		os.Exit(1)
+	}
+	content = append(content, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(content)
+	} else if err := ioutil.WriteFile(*out, content, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "bicimport: %v\n", err)
+		os.Exit(1)
+	}
+	if *strict && len(unresolved) > 0 {
+		os.Exit(1)
+	}
+}
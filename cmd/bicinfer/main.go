@@ -0,0 +1,128 @@
+// Command bicinfer drafts a bic producer configuration from sample payloads, read from
+// a file holding one or more documents (NDJSON or indented JSON) or from every .json
+// and .jsonl file of a directory. The confidence of every inferred field is written to
+// the -report file, or to stderr when it is empty.
+//
+//	bicinfer -in document.json -out config.json -report inference.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+func main() {
+	in := flag.String("in", "./document.json", "sample payloads, a file or a directory")
+	out := flag.String("out", "", "output file, stdout when empty")
+	reportPath := flag.String("report", "", "file receiving the confidence report as JSON, stderr as text when empty")
+	flag.Parse()
+
+	inferrer := bic.NewInferrer()
+	paths, err := samplePaths(*in)
+	if err != nil {
+		exit(err)
+	}
+	for _, path := range paths {
+		if err := readSamples(path, inferrer); err != nil {
+			exit(err)
+		}
+	}
+
+	config, report := inferrer.Config()
+	if report.Documents == 0 {
+		exit(fmt.Errorf("no valid sample in %v", *in))
+	}
+
+	content, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		exit(err)
+	}
+	if err := write(*out, append(content, '\n')); err != nil {
+		exit(err)
+	}
+
+	if *reportPath == "" {
+		fmt.Fprintf(os.Stderr, "bicinfer: %v documents, %v skipped\n", report.Documents, report.Skipped)
+		for _, field := range report.Fields {
+			fmt.Fprintf(os.Stderr, "%v\t%v\t%v\tconfidence %.2f\tpresent %v/%v\tnulls %v", field.Entity, field.Path, field.Type, field.Confidence, field.Present, field.Documents, field.Nulls)
+			if field.Mandatory {
+				fmt.Fprintf(os.Stderr, "\tmandatory")
+			}
+			if len(field.Enum) > 0 {
+				fmt.Fprintf(os.Stderr, "\tenum %v", strings.Join(field.Enum, ","))
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+		return
+	}
+	content, err = json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		exit(err)
+	}
+	if err := write(*reportPath, append(content, '\n')); err != nil {
+		exit(err)
+	}
+}
+
+func samplePaths(in string) ([]string, error) {
+	info, err := os.Stat(in)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{in}, nil
+	}
+	var paths []string
+	for _, pattern := range []string{"*.json", "*.jsonl"} {
+		matches, err := filepath.Glob(filepath.Join(in, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// readSamples adds every document of the file, whatever the whitespace between them.
+// Documents without a valid envelope are reported and skipped.
+func readSamples(path string, inferrer *bic.Inferrer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for {
+		var document json.RawMessage
+		if err := decoder.Decode(&document); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+		if err := inferrer.Add(document); err != nil {
+			fmt.Fprintf(os.Stderr, "bicinfer: %v: skipped %v\n", path, err)
+		}
+	}
+}
+
+func write(path string, content []byte) error {
+	if path == "" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
+
+func exit(err error) {
+	fmt.Fprintf(os.Stderr, "bicinfer: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,43 @@
+// Command bicschema converts a bic producer configuration into a JSON Schema, so both
+// validation engines can be driven from the same configuration.
+//
+//	bicschema -config bic/config-productor.json -draft 2020-12 -out schema.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+func main() {
+	configPath := flag.String("config", "./bic/config-productor.json", "producer configuration file")
+	draft := flag.String("draft", string(bic.JSONSchemaDraft07), "JSON Schema draft: draft-07 or 2020-12")
+	out := flag.String("out", "", "output file, stdout when empty")
+	flag.Parse()
+
+	config, err := bic.GetProducerConfigFromFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bicschema: error reading config %v\n", err)
+		os.Exit(1)
+	}
+
+	schema, err := bic.ToJSONSchemaDraft(config, bic.JSONSchemaDraft(*draft))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bicschema: %v\n", err)
+		os.Exit(1)
+	}
+	schema = append(schema, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := ioutil.WriteFile(*out, schema, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "bicschema: %v\n", err)
+		os.Exit(1)
+	}
+}
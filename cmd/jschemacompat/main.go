@@ -0,0 +1,76 @@
+// Command jschemacompat compares two versions of a JSON Schema and lists the changes
+// that break documents, exiting with status 1 when some break the checked direction.
+//
+//	jschemacompat -old schema.json -new jschema/schema.json -mode full
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mercadolibre/jsonschema_test/jschema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func main() {
+	oldPath := flag.String("old", "", "published version of the schema")
+	newPath := flag.String("new", "./jschema/schema.json", "new version of the schema")
+	mode := flag.String("mode", jschema.Backward, "checked direction: backward, forward or full")
+	asJSON := flag.Bool("json", false, "print the incompatibilities as JSON")
+	flag.Parse()
+
+	if *oldPath == "" || (*mode != jschema.Backward && *mode != jschema.Forward && *mode != "full") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	previous, err := load(*oldPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jschemacompat: %v\n", err)
+		os.Exit(2)
+	}
+	next, err := load(*newPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jschemacompat: %v\n", err)
+		os.Exit(2)
+	}
+
+	incompatibilities := []jschema.Incompatibility{}
+	for _, incompatibility := range jschema.CheckCompatibility(previous, next) {
+		if *mode == "full" || incompatibility.Direction == *mode {
+			incompatibilities = append(incompatibilities, incompatibility)
+		}
+	}
+	if *asJSON {
+		content, _ := json.MarshalIndent(incompatibilities, "", "    ")
+		fmt.Println(string(content))
+	} else {
+		for _, incompatibility := range incompatibilities {
+			fmt.Println(incompatibility)
+		}
+	}
+	if len(incompatibilities) > 0 {
+		os.Exit(1)
+	}
+}
+
+// load compiles a schema file through a registry of its directory, so its file
+// references and the $id of its sibling schemas resolve. The registry compiles every
+// schema of the directory, when one of them fails the file is compiled alone.
+func load(path string) (*jschema.Schema, error) {
+	schema, err := jschema.NewRegistry(filepath.Dir(path)).Get(filepath.Base(path))
+	if err == nil {
+		return schema, nil
+	}
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err = jschema.Compile(gojsonschema.NewReferenceLoader("file://" + filepath.ToSlash(absolute)))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return schema, nil
+}
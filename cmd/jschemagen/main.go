@@ -0,0 +1,48 @@
+// Command jschemagen generates Go types with a Validate method from a JSON Schema.
+//
+//	jschemagen -schema jschema/schema.json -package shipmentschema -type Shipment -out types.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mercadolibre/jsonschema_test/jschema/gen"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "./jschema/schema.json", "JSON Schema file")
+	packageName := flag.String("package", "", "package name of the generated file")
+	typeName := flag.String("type", "Document", "name of the root type")
+	out := flag.String("out", "", "output file, stdout when empty")
+	flag.Parse()
+
+	if *packageName == "" {
+		fmt.Fprintln(os.Stderr, "jschemagen: -package is required")
+		os.Exit(2)
+	}
+
+	schema, err := ioutil.ReadFile(*schemaPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jschemagen: %v\n", err)
+		os.Exit(1)
+	}
+
+	source, err := gen.GenerateTypes(schema, *packageName, *typeName, filepath.Base(*schemaPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jschemagen: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		os.Stdout.Write(source)
+		return
+	}
+	if err := ioutil.WriteFile(*out, source, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "jschemagen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,45 @@
+// Command jschemalint lints JSON Schema files, exiting with status 1 when some file has
+// issues.
+//
+//	jschemalint jschema/schema.json jschema/common/*.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mercadolibre/jsonschema_test/jschema"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: jschemalint schema.json...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, path := range flag.Args() {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jschemalint: %v\n", err)
+			os.Exit(2)
+		}
+		issues, err := jschema.Lint(content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jschemalint: %v: %v\n", path, err)
+			os.Exit(2)
+		}
+		for _, issue := range issues {
+			fmt.Printf("%v: %v\n", path, issue)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,395 @@
+package jschema
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Compatibility directions. Backward changes reject documents valid under the old
+// schema, forward changes reject documents valid under the new one.
+const (
+	Backward = "backward"
+	Forward  = "forward"
+)
+
+// Incompatibility is a change between two versions of a schema. Path locates the
+// Keyword in the new schema.
+type Incompatibility struct {
+	Direction string `json:"direction"`
+	Path      string `json:"path"`
+	Keyword   string `json:"keyword"`
+	Reason    string `json:"reason"`
+}
+
+func (incompatibility Incompatibility) String() string {
+	path := incompatibility.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%v: %v %v: %v", incompatibility.Direction, path, incompatibility.Keyword, incompatibility.Reason)
+}
+
+// compatKeywords are the keywords compared by meaning, the others are compared by value
+// and any change is reported. Annotations are ignored.
+var compatKeywords = map[string]bool{
+	"type": true, "required": true, "properties": true, "additionalProperties": true, "enum": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"minLength": true, "maxLength": true, "minItems": true, "maxItems": true,
+	"minProperties": true, "maxProperties": true, "dependencies": true, "items": true, "$ref": true,
+	"$schema": true, "$id": true, "id": true, "definitions": true, "title": true, "description": true,
+	"default": true, "examples": true, "$comment": true,
+}
+
+// CheckCompatibility lists the backward and forward incompatibilities between two
+// versions of a schema, sorted by direction and path. Only local references are followed.
+func CheckCompatibility(previous *Schema, next *Schema) []Incompatibility {
+	backward := compatWalker{fromRoot: previous.Document, toRoot: next.Document, nextIsTo: true, direction: Backward, visited: make(map[string]bool)}
+	backward.compare(previous.Document, next.Document, "", 0)
+	forward := compatWalker{fromRoot: next.Document, toRoot: previous.Document, direction: Forward, visited: make(map[string]bool)}
+	forward.compare(next.Document, previous.Document, "", 0)
+
+	incompatibilities := append(backward.incompatibilities, forward.incompatibilities...)
+	sort.SliceStable(incompatibilities, func(i, j int) bool {
+		if incompatibilities[i].Direction != incompatibilities[j].Direction {
+			return incompatibilities[i].Direction < incompatibilities[j].Direction
+		}
+		if incompatibilities[i].Path != incompatibilities[j].Path {
+			return incompatibilities[i].Path < incompatibilities[j].Path
+		}
+		return incompatibilities[i].Keyword < incompatibilities[j].Keyword
+	})
+	return incompatibilities
+}
+
+// compatWalker finds the values accepted by the from schema and rejected by the to
+// schema.
+type compatWalker struct {
+	fromRoot          interface{}
+	toRoot            interface{}
+	nextIsTo          bool
+	direction         string
+	visited           map[string]bool
+	incompatibilities []Incompatibility
+}
+
+func (walker *compatWalker) report(path string, keyword string, format string, args ...interface{}) {
+	walker.incompatibilities = append(walker.incompatibilities, Incompatibility{
+		Direction: walker.direction,
+		Path:      path,
+		Keyword:   keyword,
+		Reason:    fmt.Sprintf(format, args...),
+	})
+}
+
+// deref follows the local references of node, returning the last reference followed.
+func deref(root interface{}, node interface{}) (interface{}, string) {
+	var last string
+	for depth := 0; depth <= maxLocateDepth; depth++ {
+		object, _ := node.(map[string]interface{})
+		ref, isRef := object["$ref"].(string)
+		if !isRef || !strings.HasPrefix(ref, "#") {
+			break
+		}
+		node, last = resolveRef(root, ref), ref
+	}
+	return node, last
+}
+
+func (walker *compatWalker) compare(from interface{}, to interface{}, path string, depth int) {
+	from, fromRef := deref(walker.fromRoot, from)
+	to, toRef := deref(walker.toRoot, to)
+	newRef := fromRef
+	if walker.nextIsTo {
+		newRef = toRef
+	}
+	if newRef != "" {
+		path = strings.TrimSuffix(newRef[1:], "/")
+	}
+	if fromRef != "" && toRef != "" {
+		key := fromRef + "\x00" + toRef
+		if walker.visited[key] {
+			return
+		}
+		walker.visited[key] = true
+	}
+	if depth > maxLocateDepth {
+		return
+	}
+
+	if accept, isBool := to.(bool); isBool && !accept {
+		if accept, isBool := from.(bool); !isBool || accept {
+			walker.report(path, "false", "rejects every value")
+		}
+		return
+	}
+	if accept, isBool := from.(bool); isBool && !accept {
+		return
+	}
+	fromObject, _ := from.(map[string]interface{})
+	toObject, _ := to.(map[string]interface{})
+
+	walker.compareTypes(fromObject, toObject, path)
+	walker.compareRequired(fromObject, toObject, path)
+	walker.compareEnum(fromObject, toObject, path)
+	walker.compareBounds(fromObject, toObject, path)
+	walker.compareProperties(fromObject, toObject, path, depth)
+	walker.compareDependencies(fromObject, toObject, path, depth)
+	if fromObject["items"] != nil || toObject["items"] != nil {
+		_, fromTuple := fromObject["items"].([]interface{})
+		_, toTuple := toObject["items"].([]interface{})
+		if !fromTuple && !toTuple {
+			walker.compare(schemaOrTrue(fromObject["items"]), schemaOrTrue(toObject["items"]), path+"/items", depth+1)
+		} else if !equalValues(fromObject["items"], toObject["items"]) {
+			walker.report(path, "items", "changed, tuples aren't compared")
+		}
+	}
+
+	keywords := make([]string, 0, len(toObject))
+	for keyword := range toObject {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		if !compatKeywords[keyword] && !equalValues(fromObject[keyword], toObject[keyword]) {
+			walker.report(path, keyword, "changed, the keyword isn't compared")
+		}
+	}
+}
+
+// schemaOrTrue returns the schema, or true for a missing one.
+func schemaOrTrue(schema interface{}) interface{} {
+	if schema == nil {
+		return true
+	}
+	return schema
+}
+
+func typeSet(object map[string]interface{}) map[string]bool {
+	types := make(map[string]bool)
+	switch value := object["type"].(type) {
+	case string:
+		types[value] = true
+	case []interface{}:
+		for _, name := range value {
+			if name, ok := name.(string); ok {
+				types[name] = true
+			}
+		}
+	default:
+		return nil
+	}
+	if types["number"] {
+		types["integer"] = true
+	}
+	return types
+}
+
+func (walker *compatWalker) compareTypes(from map[string]interface{}, to map[string]interface{}, path string) {
+	toTypes := typeSet(to)
+	if toTypes == nil {
+		return
+	}
+	fromTypes := typeSet(from)
+	if fromTypes == nil {
+		walker.report(path, "type", "restricts any type to %v", to["type"])
+		return
+	}
+	var removed []string
+	for name := range fromTypes {
+		if !toTypes[name] {
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		walker.report(path, "type", "rejects %v", strings.Join(removed, ", "))
+	}
+}
+
+func (walker *compatWalker) compareRequired(from map[string]interface{}, to map[string]interface{}, path string) {
+	fromRequired := make(map[string]bool)
+	for _, name := range stringItems(from["required"]) {
+		fromRequired[name] = true
+	}
+	for _, name := range stringItems(to["required"]) {
+		if !fromRequired[name] {
+			walker.report(path, "required", "requires %v", name)
+		}
+	}
+}
+
+func (walker *compatWalker) compareEnum(from map[string]interface{}, to map[string]interface{}, path string) {
+	toValues, ok := to["enum"].([]interface{})
+	if !ok {
+		return
+	}
+	fromValues, ok := from["enum"].([]interface{})
+	if !ok {
+		walker.report(path, "enum", "restricts the values to an enum")
+		return
+	}
+	for _, value := range fromValues {
+		found := false
+		for _, other := range toValues {
+			if equalValues(value, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			walker.report(path, "enum", "rejects %v", value)
+		}
+	}
+}
+
+func (walker *compatWalker) compareBounds(from map[string]interface{}, to map[string]interface{}, path string) {
+	for _, lower := range []bool{true, false} {
+		toBound, toExclusive, keyword, ok := numberBound(to, lower)
+		if !ok {
+			continue
+		}
+		fromBound, fromExclusive, _, ok := numberBound(from, lower)
+		comparison := 0
+		if ok {
+			comparison = toBound.Cmp(fromBound)
+			if !lower {
+				comparison = -comparison
+			}
+		}
+		if !ok || comparison > 0 || (comparison == 0 && toExclusive && !fromExclusive) {
+			walker.report(path, keyword, "narrows the range to %v", toBound.RatString())
+		}
+	}
+
+	for _, keywords := range [][2]string{{"minLength", "maxLength"}, {"minItems", "maxItems"}, {"minProperties", "maxProperties"}} {
+		for i, keyword := range keywords {
+			toValue, ok := rat(to[keyword])
+			if !ok {
+				continue
+			}
+			fromValue, ok := rat(from[keyword])
+			if !ok || (i == 0 && toValue.Cmp(fromValue) > 0) || (i == 1 && toValue.Cmp(fromValue) < 0) {
+				walker.report(path, keyword, "narrows the limit to %v", toValue.RatString())
+			}
+		}
+	}
+}
+
+// numberBound returns the stricter lower or upper bound of a schema, with the boolean
+// exclusive keywords of draft-04 and the numeric ones of later drafts.
+func numberBound(object map[string]interface{}, lower bool) (*big.Rat, bool, string, bool) {
+	inclusive, exclusive := "maximum", "exclusiveMaximum"
+	if lower {
+		inclusive, exclusive = "minimum", "exclusiveMinimum"
+	}
+	bound, ok := rat(object[inclusive])
+	isExclusive := ok && object[exclusive] == true
+	keyword := inclusive
+	if value, numeric := rat(object[exclusive]); numeric {
+		comparison := 0
+		if ok {
+			comparison = value.Cmp(bound)
+			if !lower {
+				comparison = -comparison
+			}
+		}
+		if !ok || comparison >= 0 {
+			bound, isExclusive, keyword, ok = value, true, exclusive, true
+		}
+	}
+	return bound, isExclusive, keyword, ok
+}
+
+func rat(value interface{}) (*big.Rat, bool) {
+	var text string
+	switch value := value.(type) {
+	case fmt.Stringer:
+		text = value.String()
+	case float64, int:
+		text = fmt.Sprint(value)
+	default:
+		return nil, false
+	}
+	return new(big.Rat).SetString(text)
+}
+
+func (walker *compatWalker) compareProperties(from map[string]interface{}, to map[string]interface{}, path string, depth int) {
+	fromProperties, _ := from["properties"].(map[string]interface{})
+	toProperties, _ := to["properties"].(map[string]interface{})
+	names := make(map[string]bool)
+	for name := range fromProperties {
+		names[name] = true
+	}
+	for name := range toProperties {
+		names[name] = true
+	}
+	for _, name := range sortedKeys(names) {
+		fromProperty, ok := fromProperties[name]
+		if !ok {
+			fromProperty = schemaOrTrue(from["additionalProperties"])
+		}
+		toProperty, ok := toProperties[name]
+		if !ok {
+			toProperty = schemaOrTrue(to["additionalProperties"])
+		}
+		walker.compare(fromProperty, toProperty, path+"/properties/"+escape(name), depth+1)
+	}
+	if from["additionalProperties"] != nil || to["additionalProperties"] != nil {
+		walker.compare(schemaOrTrue(from["additionalProperties"]), schemaOrTrue(to["additionalProperties"]), path+"/additionalProperties", depth+1)
+	}
+}
+
+func (walker *compatWalker) compareDependencies(from map[string]interface{}, to map[string]interface{}, path string, depth int) {
+	fromDependencies, _ := from["dependencies"].(map[string]interface{})
+	toDependencies, _ := to["dependencies"].(map[string]interface{})
+	required := make(map[string]bool)
+	for _, name := range stringItems(from["required"]) {
+		required[name] = true
+	}
+	for _, name := range sortedKeys(toDependencies) {
+		dependencyPath := path + "/dependencies/" + escape(name)
+		names, isList := toDependencies[name].([]interface{})
+		if !isList {
+			walker.compare(schemaOrTrue(fromDependencies[name]), toDependencies[name], dependencyPath, depth+1)
+			continue
+		}
+		fromNames := make(map[string]bool)
+		for _, other := range stringItems(fromDependencies[name]) {
+			fromNames[other] = true
+		}
+		for _, other := range stringItems(names) {
+			if !fromNames[other] && !required[other] {
+				walker.report(dependencyPath, "dependencies", "%v requires %v", name, other)
+			}
+		}
+	}
+}
+
+func stringItems(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var names []string
+	for _, item := range items {
+		if name, ok := item.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func sortedKeys(set interface{}) []string {
+	var keys []string
+	switch set := set.(type) {
+	case map[string]bool:
+		for key := range set {
+			keys = append(keys, key)
+		}
+	case map[string]interface{}:
+		for key := range set {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
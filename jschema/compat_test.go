@@ -0,0 +1,103 @@
+package jschema
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+var compatOld = []byte(`{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["entity"],
+	"properties": {
+		"entity": {"type": "string", "enum": ["SHIPMENT", "ORDER"]},
+		"id": {"type": ["string", "integer"]},
+		"metrics": {
+			"type": "object",
+			"properties": {
+				"lead_time": {"$ref": "#/definitions/days"},
+				"tags": {"type": "array", "items": {"type": "string", "maxLength": 10}}
+			},
+			"dependencies": {"lead_time": ["tags"]}
+		}
+	},
+	"definitions": {"days": {"type": "number", "minimum": 0, "maximum": 30}}
+}`)
+
+var compatNew = []byte(`{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["entity", "id"],
+	"properties": {
+		"entity": {"type": "string", "enum": ["SHIPMENT", "ORDER", "ITEM"], "description": "changed"},
+		"id": {"type": "string", "pattern": "^[0-9]+$"},
+		"metrics": {
+			"type": "object",
+			"properties": {
+				"lead_time": {"$ref": "#/definitions/days"},
+				"tags": {"type": "array", "items": {"type": "string", "maxLength": 20}}
+			},
+			"additionalProperties": false,
+			"dependencies": {"lead_time": ["tags", "offset"]}
+		}
+	},
+	"definitions": {"days": {"type": "integer", "exclusiveMinimum": 0, "maximum": 60}}
+}`)
+
+func TestCheckCompatibility(t *testing.T) {
+	old, err := CompileBytes(compatOld)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	new, err := CompileBytes(compatNew)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+
+	var found []string
+	for _, incompatibility := range CheckCompatibility(old, new) {
+		found = append(found, incompatibility.Direction+" "+incompatibility.Path+" "+incompatibility.Keyword)
+	}
+	expected := []string{
+		"backward  required",
+		"backward /definitions/days exclusiveMinimum",
+		"backward /definitions/days type",
+		"backward /properties/id pattern",
+		"backward /properties/id type",
+		"backward /properties/metrics/additionalProperties false",
+		"backward /properties/metrics/dependencies/lead_time dependencies",
+		"forward /definitions/days maximum",
+		"forward /properties/entity enum",
+		"forward /properties/metrics/properties/tags/items maxLength",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+}
+
+func TestCheckCompatibilityUnchanged(t *testing.T) {
+	content, err := ioutil.ReadFile("schema.json")
+	if err != nil {
+		t.Fatalf("Error reading schema %v", err)
+	}
+	schema, err := CompileBytes(content)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	if incompatibilities := CheckCompatibility(schema, schema); len(incompatibilities) != 0 {
+		t.Errorf("expected no incompatibilities, got %v", incompatibilities)
+	}
+
+	draft04, err := CompileBytes([]byte(`{"$schema": "http://json-schema.org/draft-04/schema#", "minimum": 0, "exclusiveMinimum": true}`))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	inclusive, err := CompileBytes([]byte(`{"$schema": "http://json-schema.org/draft-04/schema#", "minimum": 0}`))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	if incompatibilities := CheckCompatibility(inclusive, draft04); len(incompatibilities) != 1 || incompatibilities[0].Direction != Backward {
+		t.Errorf("expected the exclusive minimum to be backward incompatible, got %v", incompatibilities)
+	}
+}
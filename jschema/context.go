@@ -0,0 +1,75 @@
+package jschema
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/mercadolibre/jsonschema_test/stopwatch"
+)
+
+// backgroundValidations bounds the goroutines of ValidateBytesContext, the abandoned
+// ones included: gojsonschema cannot be interrupted, so they run until the validation
+// finishes. Once it is full, callers wait for a slot or for their context.
+var backgroundValidations = make(chan struct{}, 4*runtime.GOMAXPROCS(0))
+
+type validationOutcome struct {
+	valid bool
+	err   error
+}
+
+// ValidateBytesContext behaves like ValidateCompiledBytes but gives up as soon as ctx is done,
+// with the same validation_timeout or validation_canceled error as bic. The document is
+// first read in the calling goroutine, checking ctx and rejecting the documents past
+// bic.DefaultLimits with the errors of bic, so only the documents within the limits take
+// a slot. Contexts that can't be done validate in the calling goroutine.
+func ValidateBytesContext(ctx context.Context, doc []byte, schema *Schema) (bool, error) {
+	defer stopwatch.Track(ctx)()
+
+	if err := ctx.Err(); err != nil {
+		return false, bic.NewContextApiError(err)
+	}
+	if err := checkDocument(ctx, doc); err != nil {
+		return false, err
+	}
+	if ctx.Done() == nil {
+		return ValidateCompiledBytes(doc, schema)
+	}
+
+	select {
+	case backgroundValidations <- struct{}{}:
+	case <-ctx.Done():
+		return false, bic.NewContextApiError(ctx.Err())
+	}
+	outcome := make(chan validationOutcome, 1)
+	go func() {
+		defer func() { <-backgroundValidations }()
+		valid, err := ValidateCompiledBytes(doc, schema)
+		outcome <- validationOutcome{valid, err}
+	}()
+
+	select {
+	case result := <-outcome:
+		return result.valid, result.err
+	case <-ctx.Done():
+		return false, bic.NewContextApiError(ctx.Err())
+	}
+}
+
+// checkDocument reads the tokens of doc, failing when ctx is done or when doc exceeds
+// bic.DefaultLimits. Malformed documents are left to gojsonschema, which reports them.
+func checkDocument(ctx context.Context, doc []byte) error {
+	decoder := bic.NewPayloadDecoderContext(ctx, doc, bic.DefaultLimits)
+	token, err := decoder.Token()
+	if err == nil {
+		if err = decoder.Skip(token); err == nil {
+			err = decoder.End()
+		}
+	}
+	if limitError, isLimit := err.(*bic.LimitError); isLimit {
+		return bic.NewLimitApiError(limitError)
+	} else if err != nil && err == ctx.Err() {
+		return bic.NewContextApiError(err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package jschema
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestValidateBytesContext(t *testing.T) {
+	schema, err := Compile(gojsonschema.NewReferenceLoader("file://./schema.json"))
+	if err != nil {
+		t.Fatalf("Error reading schema %v", err)
+	}
+	doc := []byte(`{"entity": "SHIPMENT_TEST", "id": "1", "version": "0.0.1", "metrics": {"handling_time": {"date_from": "2019-10-11T13:38:29-03:00"}, "lead_time": {"estimated_days": 3, "shipping_offset_days": 2}}}`)
+
+	if valid, err := ValidateBytesContext(context.Background(), doc, schema); !valid {
+		t.Errorf("expected valid document, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ValidateBytesContext(canceled, doc, schema); !isContextError(err, "validation_canceled") {
+		t.Errorf("expected validation_canceled, got %v", err)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if _, err := ValidateBytesContext(expired, doc, schema); !isContextError(err, "validation_timeout") {
+		t.Errorf("expected validation_timeout, got %v", err)
+	}
+}
+
+// blockingChecker holds the validation in its format until released, so the context
+// can be canceled while the document is being validated.
+type blockingChecker struct {
+	entered  chan bool
+	released chan bool
+}
+
+func (checker blockingChecker) IsFormat(input interface{}) bool {
+	checker.entered <- true
+	<-checker.released
+	return true
+}
+
+func TestValidateBytesContextCanceledWhileValidating(t *testing.T) {
+	checker := blockingChecker{entered: make(chan bool), released: make(chan bool)}
+	gojsonschema.FormatCheckers.Add("test-blocking", checker)
+	defer gojsonschema.FormatCheckers.Remove("test-blocking")
+
+	schema, err := CompileBytes([]byte(`{"type": "string", "format": "test-blocking"}`))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		_, err := ValidateBytesContext(ctx, []byte(`"value"`), schema)
+		result <- err
+	}()
+
+	<-checker.entered
+	cancel()
+	if err := <-result; !isContextError(err, "validation_canceled") {
+		t.Errorf("expected validation_canceled, got %v", err)
+	}
+	if len(backgroundValidations) != 1 {
+		t.Errorf("expected the abandoned validation to keep its slot, got %v", len(backgroundValidations))
+	}
+
+	//Once the abandoned validation finishes its slot is free again
+	close(checker.released)
+	for deadline := time.Now().Add(time.Second); len(backgroundValidations) > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if len(backgroundValidations) != 0 {
+		t.Errorf("expected the slot to be freed, %v still taken", len(backgroundValidations))
+	}
+	plain, err := CompileBytes([]byte(`{"type": "string"}`))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	for i := 0; i < cap(backgroundValidations)+1; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		valid, err := ValidateBytesContext(ctx, []byte(`"value"`), plain)
+		cancel()
+		if !valid {
+			t.Fatalf("expected valid document, got %v", err)
+		}
+	}
+}
+
+func TestValidateBytesContextLimits(t *testing.T) {
+	schema, err := CompileBytes([]byte(`{}`))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	deep := []byte(strings.Repeat("[", bic.DefaultLimits.MaxDepth+1) + strings.Repeat("]", bic.DefaultLimits.MaxDepth+1))
+	if _, err := ValidateBytesContext(ctx, deep, schema); !isApiError(err, "unprocessable_entity") {
+		t.Errorf("expected unprocessable_entity, got %v", err)
+	}
+	large := []byte(`"` + strings.Repeat("a", bic.DefaultLimits.MaxBytes) + `"`)
+	if _, err := ValidateBytesContext(ctx, large, schema); !isApiError(err, "payload_too_large") {
+		t.Errorf("expected payload_too_large, got %v", err)
+	}
+	if _, err := ValidateBytesContext(ctx, []byte(`{"a": `), schema); err == nil || isApiError(err, "payload_too_large") {
+		t.Errorf("expected gojsonschema to report the malformed document, got %v", err)
+	}
+}
+
+func isApiError(err error, code string) bool {
+	apiError, ok := err.(apierrors.ApiError)
+	return ok && apiError.Code() == code
+}
+
+func isContextError(err error, code string) bool {
+	apiError, ok := err.(apierrors.ApiError)
+	return ok && apiError.Code() == code && apiError.Status() == http.StatusRequestTimeout
+}
@@ -0,0 +1,128 @@
+package jschema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AppliedDefault is a default value written into a document, Path is the JSON Pointer
+// of the property that was missing.
+type AppliedDefault struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// ApplyDefaults validates doc and fills its missing optional properties with their
+// default. Defaults come from properties, following local references, allOf and items;
+// the branches of anyOf, oneOf and if are not applied since they depend on the document.
+// Nested defaults apply inside defaulted objects too. The completed document is
+// validated again and returned with sorted keys; when either validation fails the
+// error is, or wraps, a *ValidationError.
+func ApplyDefaults(doc []byte, schema *Schema) ([]byte, []AppliedDefault, error) {
+	if valid, err := ValidateCompiledBytes(doc, schema); !valid {
+		return nil, nil, err
+	}
+
+	document, err := decode(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	walker := defaultsWalker{root: schema.Document}
+	walker.walk(schema.Document, document, "", 0)
+
+	content, err := encode(document)
+	if err != nil {
+		return nil, nil, err
+	}
+	if valid, err := ValidateCompiledBytes(content, schema); !valid {
+		return nil, nil, fmt.Errorf("jschema: the defaults make the document invalid: %w", err)
+	}
+	return content, walker.applied, nil
+}
+
+type defaultsWalker struct {
+	root    interface{}
+	applied []AppliedDefault
+}
+
+func (walker *defaultsWalker) walk(node interface{}, value interface{}, path string, depth int) {
+	object, ok := node.(map[string]interface{})
+	if !ok || depth > maxLocateDepth {
+		return
+	}
+	if ref, isRef := object["$ref"].(string); isRef {
+		if strings.HasPrefix(ref, "#") {
+			walker.walk(resolveRef(walker.root, ref), value, path, depth+1)
+		}
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		properties, _ := object["properties"].(map[string]interface{})
+		required := make(map[string]bool)
+		requiredList, _ := object["required"].([]interface{})
+		for _, key := range requiredList {
+			if key, ok := key.(string); ok {
+				required[key] = true
+			}
+		}
+
+		keys := make([]string, 0, len(properties))
+		for key := range properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			resolved, _ := deref(walker.root, properties[key])
+			property, _ := resolved.(map[string]interface{})
+			if _, present := value[key]; !present && !required[key] {
+				if defaultValue, ok := property["default"]; ok {
+					value[key] = copyValue(defaultValue)
+					walker.applied = append(walker.applied, AppliedDefault{Path: path + "/" + escape(key), Value: copyValue(defaultValue)})
+				}
+			}
+			if _, present := value[key]; present {
+				walker.walk(properties[key], value[key], path+"/"+escape(key), depth+1)
+			}
+		}
+	case []interface{}:
+		switch items := object["items"].(type) {
+		case map[string]interface{}:
+			for i := range value {
+				walker.walk(items, value[i], fmt.Sprintf("%v/%v", path, i), depth+1)
+			}
+		case []interface{}:
+			for i := 0; i < len(value) && i < len(items); i++ {
+				walker.walk(items[i], value[i], fmt.Sprintf("%v/%v", path, i), depth+1)
+			}
+		}
+	}
+
+	branches, _ := object["allOf"].([]interface{})
+	for _, branch := range branches {
+		walker.walk(branch, value, path, depth+1)
+	}
+}
+
+// copyValue returns a deep copy of a decoded JSON value, so the schema document is never
+// modified through the documents.
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,107 @@
+package jschema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var defaultsSchema = []byte(`{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["entity"],
+	"properties": {
+		"entity": {"type": "string", "default": "SHIPMENT"},
+		"status": {"type": "string", "default": "enabled"},
+		"metrics": {
+			"type": "object",
+			"default": {},
+			"properties": {
+				"lead_time": {"$ref": "#/definitions/days"},
+				"tags": {"type": "array", "items": {"$ref": "#/definitions/tag"}}
+			}
+		}
+	},
+	"allOf": [{"properties": {"version": {"type": "string", "default": "0.0.1"}}}],
+	"anyOf": [{"properties": {"ignored": {"default": true}}}],
+	"definitions": {
+		"days": {"type": "number", "minimum": 0, "default": 1.50},
+		"tag": {"type": "object", "properties": {"name": {"type": "string"}, "weight": {"type": "integer", "default": 0}}}
+	}
+}`)
+
+func TestApplyDefaults(t *testing.T) {
+	schema, err := CompileBytes(defaultsSchema)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+
+	tests := []struct {
+		doc      string
+		expected string
+		applied  []string
+	}{
+		{`{"entity": "ORDER"}`, `{"entity":"ORDER","metrics":{"lead_time":1.50},"status":"enabled","version":"0.0.1"}`,
+			[]string{"/metrics", "/metrics/lead_time", "/status", "/version"}},
+		{`{"entity": "A", "status": "disabled", "version": "1", "metrics": {"lead_time": 3, "tags": [{"name": "<a>"}, {"weight": 2}]}}`,
+			`{"entity":"A","metrics":{"lead_time":3,"tags":[{"name":"<a>","weight":0},{"weight":2}]},"status":"disabled","version":"1"}`,
+			[]string{"/metrics/tags/0/weight"}},
+	}
+	for _, test := range tests {
+		completed, applied, err := ApplyDefaults([]byte(test.doc), schema)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", test.doc, err)
+		}
+		if string(completed) != test.expected {
+			t.Errorf("%v: expected %v, got %s", test.doc, test.expected, completed)
+		}
+		var paths []string
+		for _, value := range applied {
+			paths = append(paths, value.Path)
+		}
+		if !reflect.DeepEqual(paths, test.applied) {
+			t.Errorf("%v: expected defaults %v, got %+v", test.doc, test.applied, applied)
+		}
+	}
+
+	//Los defaults se copian, el schema no cambia entre documentos
+	if _, applied, _ := ApplyDefaults([]byte(`{"entity": "A"}`), schema); applied[0].Value.(map[string]interface{})["lead_time"] != nil {
+		t.Errorf("the metrics default was modified: %+v", applied[0].Value)
+	}
+	if value, _ := applied(t, schema, `{"entity": "A"}`, "/metrics/lead_time").(json.Number); value != "1.50" {
+		t.Errorf("expected the lead_time default as a json.Number, got %v", value)
+	}
+}
+
+func TestApplyDefaultsInvalid(t *testing.T) {
+	schema, err := CompileBytes(defaultsSchema)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	var validationError *ValidationError
+	if _, _, err := ApplyDefaults([]byte(`{"status": "enabled"}`), schema); !errors.As(err, &validationError) {
+		t.Errorf("expected a ValidationError, got %v", err)
+	}
+
+	broken, err := CompileBytes([]byte(`{"properties": {"days": {"type": "integer", "default": "none"}}}`))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	if _, _, err := ApplyDefaults([]byte(`{}`), broken); !errors.As(err, &validationError) {
+		t.Errorf("expected a ValidationError for an invalid default, got %v", err)
+	}
+}
+
+func applied(t *testing.T, schema *Schema, doc string, path string) interface{} {
+	_, defaults, err := ApplyDefaults([]byte(doc), schema)
+	if err != nil {
+		t.Fatalf("%v: unexpected error %v", doc, err)
+	}
+	for _, value := range defaults {
+		if value.Path == path {
+			return value.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package jschema
+
+import (
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// LeafFormats maps the formats registered for the bic leaf types, named by
+// bic.LeafFormat, to their leaf type. The standard formats, date and time among them,
+// keep the gojsonschema checkers. Each format only agrees with bic when the schema also
+// sets the type of bic.LeafFormatType, which Lint reports when it is missing.
+var LeafFormats = make(map[string]string)
+
+func init() {
+	for _, leafType := range []string{"boolean_number", "date", "time", "datetime"} {
+		LeafFormats[bic.LeafFormat(leafType)] = leafType
+		gojsonschema.FormatCheckers.Add(bic.LeafFormat(leafType), bic.LeafFormatChecker(leafType))
+	}
+}
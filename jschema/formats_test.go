@@ -0,0 +1,82 @@
+package jschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+// TestLeafFormatsAgreeWithBic validates the same values with a bic config and with a
+// schema using the leaf types as formats. bic validates the objects sent for a leaf as
+// blocks, so they are only compared with IsLeafType.
+func TestLeafFormatsAgreeWithBic(t *testing.T) {
+	previousLogger := bic.DefaultLogger
+	bic.DefaultLogger = bic.NopLogger{}
+	defer func() { bic.DefaultLogger = previousLogger }()
+	values := []string{`0`, `1`, `1.0`, `0.5`, `2`, `-1`, `"1"`, `"2020-06-04"`, `"2020-13-01"`, `"12:30:00"`, `"25:61:00"`,
+		`"2019-10-11T13:38:29-03:00"`, `"2019-10-11T13:38:29"`, `"on 2020-06-04"`, `"x"`,
+		`true`, `[1]`, `["2020-06-04"]`}
+
+	for format, leafType := range LeafFormats {
+		config, configErr := bic.GetProducerConfig("1", []byte(fmt.Sprintf(`{"entity": "E", "status": "enabled", "allowed_metrics": {"block": {"value": %q}}}`, leafType)))
+		if configErr != nil {
+			t.Fatalf("Error reading config %v", configErr)
+		}
+		leafSchema := fmt.Sprintf(`{"type": %q, "format": %q}`, bic.LeafFormatType(leafType), format)
+		schema, err := CompileBytes([]byte(`{"properties": {"metrics": {"properties": {"block": {"properties": {"value": ` + leafSchema + `}}}}}}`))
+		if err != nil {
+			t.Fatalf("Error compiling schema %v", err)
+		}
+		valueSchema, err := CompileBytes([]byte(leafSchema))
+		if err != nil {
+			t.Fatalf("Error compiling schema %v", err)
+		}
+
+		for _, value := range values {
+			payload := []byte(fmt.Sprintf(`{"entity": "E", "id": "1", "metrics": {"block": {"value": %v}}}`, value))
+			bicValid, _ := bic.Validate(payload, config)
+			schemaValid, err := ValidateCompiledBytes(payload, schema)
+			if bicValid != schemaValid {
+				t.Errorf("%v %v: bic says %v, the schema says %v (%v)", format, value, bicValid, schemaValid, err)
+			}
+		}
+		for _, value := range append(values, `{}`, `{"value": 1}`) {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+				t.Fatalf("Error decoding %v %v", value, err)
+			}
+			leafValid := bic.IsLeafType(decoded, leafType)
+			if schemaValid, err := ValidateCompiledBytes([]byte(value), valueSchema); leafValid != schemaValid {
+				t.Errorf("%v %v: IsLeafType says %v, the schema says %v (%v)", format, value, leafValid, schemaValid, err)
+			}
+		}
+	}
+}
+
+// TestStandardFormatsUnchanged checks that the bic leaf types don't loosen the standard
+// date and time formats.
+func TestStandardFormatsUnchanged(t *testing.T) {
+	tests := []struct {
+		format string
+		value  string
+		valid  bool
+	}{
+		{"date", `"2019-10-11"`, true},
+		{"date", `"garbage 2019-10-11 garbage"`, false},
+		{"date", `"2019-10-11T99"`, false},
+		{"time", `"23:59:59"`, true},
+		{"time", `"xx23:59:59yy"`, false},
+		{"bic-date", `"garbage 2019-10-11 garbage"`, true},
+	}
+	for _, test := range tests {
+		schema, err := CompileBytes([]byte(fmt.Sprintf(`{"format": %q}`, test.format)))
+		if err != nil {
+			t.Fatalf("Error compiling schema %v", err)
+		}
+		if valid, err := ValidateCompiledBytes([]byte(test.value), schema); valid != test.valid {
+			t.Errorf("%v %v: expected %v, got %v (%v)", test.format, test.value, test.valid, valid, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package gen generates Go types from JSON Schemas.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type generator struct {
+	buffer bytes.Buffer
+	names  map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buffer, format, args...)
+}
+
+func (g *generator) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.names[unique] = true
+	return unique
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// identifier turns keys like "handling_time" or "SHIPMENT_TEST" into exported Go names.
+func identifier(key string) string {
+	var builder strings.Builder
+	upper := true
+	for _, r := range strings.ToLower(key) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		builder.WriteRune(r)
+	}
+	name := builder.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "M" + name
+	}
+	return name
+}
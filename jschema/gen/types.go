@@ -0,0 +1,367 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// Kinds of the Go types generated for a schema.
+const (
+	anyKind = iota
+	scalarKind
+	structKind
+	sliceKind
+	mapKind
+)
+
+// schemaType is the Go type of a subschema. Scalars and structs get a pointer when
+// optional or nullable, slices, maps and interfaces are nil instead.
+type schemaType struct {
+	name     string
+	kind     int
+	nullable bool
+	elem     *schemaType
+}
+
+func (t schemaType) declaration(optional bool) string {
+	if (t.kind == scalarKind || t.kind == structKind) && (optional || t.nullable) {
+		return "*" + t.name
+	}
+	return t.name
+}
+
+// validates tells whether values of the type have a Validate method to call.
+func (t schemaType) validates() bool {
+	if t.kind == structKind {
+		return true
+	}
+	return t.elem != nil && t.elem.validates()
+}
+
+// GenerateTypes returns the source of a Go file with one struct per object of a JSON
+// Schema, typeName being the root one. Optional properties are pointers, date-time
+// strings time.Time and integers int64. The Validate method of each struct checks the
+// required and dependencies keywords: the keys they name are looked for in the decoded
+// JSON, past them a nil field is an absent key. The other keywords are left to the schema.
+// Only local references are followed. source is only used in the header comment.
+func GenerateTypes(schema []byte, packageName string, typeName string, source string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(schema))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("reading schema %v: %v", source, err)
+	}
+	if _, isObject := document.(map[string]interface{}); !isObject {
+		return nil, fmt.Errorf("schema %v is not an object", source)
+	}
+
+	g := &typesGenerator{generator: generator{names: make(map[string]bool)}, root: document, refs: make(map[string]*schemaType)}
+	if root := g.resolve(document, typeName, ""); root.kind != structKind {
+		return nil, fmt.Errorf("schema %v does not describe an object with properties", source)
+	}
+	for len(g.pending) > 0 {
+		generate := g.pending[0]
+		g.pending = g.pending[1:]
+		generate()
+	}
+
+	var header generator
+	header.printf("// Code generated by jschemagen from %v. DO NOT EDIT.\n\n", source)
+	header.printf("package %v\n\n", packageName)
+	var imports []string
+	if g.usesJSON {
+		imports = append(imports, `"encoding/json"`)
+	}
+	if g.usesFmt {
+		imports = append(imports, `"fmt"`)
+	}
+	if g.usesTime {
+		imports = append(imports, `"time"`)
+	}
+	if len(imports) > 0 {
+		header.printf("import (\n%v\n)\n\n", strings.Join(imports, "\n"))
+	}
+	header.buffer.Write(g.buffer.Bytes())
+
+	formatted, err := format.Source(header.buffer.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return formatted, nil
+}
+
+type typesGenerator struct {
+	generator
+	root     interface{}
+	refs     map[string]*schemaType
+	pending  []func()
+	usesJSON bool
+	usesFmt  bool
+	usesTime bool
+}
+
+// resolve returns the Go type of the subschema at path, queueing the declaration of its
+// structs. name is the type name of an object.
+func (g *typesGenerator) resolve(node interface{}, name string, path string) schemaType {
+	object, _ := node.(map[string]interface{})
+	if ref, isRef := object["$ref"].(string); isRef {
+		if !strings.HasPrefix(ref, "#") {
+			return schemaType{name: "interface{}", kind: anyKind}
+		}
+		if resolved, ok := g.refs[ref]; ok {
+			return *resolved
+		}
+		tokens := strings.Split(strings.TrimPrefix(ref[1:], "/"), "/")
+		target, refName := g.root, tokens[len(tokens)-1]
+		for _, token := range tokens {
+			if token == "" {
+				continue
+			}
+			parent, _ := target.(map[string]interface{})
+			target = parent[strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)]
+		}
+		if refName == "" {
+			refName = name
+		}
+		//The type is registered before resolving it, so recursive references end
+		resolved := &schemaType{name: g.uniqueName(identifier(refName)), kind: structKind}
+		g.refs[ref] = resolved
+		*resolved = g.resolveNamed(target, resolved.name, ref[1:], true)
+		return *resolved
+	}
+	return g.resolveNamed(node, name, path, false)
+}
+
+// resolveNamed resolves a subschema without reference, reserved tells whether name is
+// already unique.
+func (g *typesGenerator) resolveNamed(node interface{}, name string, path string, reserved bool) schemaType {
+	object, _ := node.(map[string]interface{})
+	var types []string
+	nullable := false
+	switch value := object["type"].(type) {
+	case string:
+		types = []string{value}
+	case []interface{}:
+		for _, item := range value {
+			if item == "null" {
+				nullable = true
+			} else if item, ok := item.(string); ok {
+				types = append(types, item)
+			}
+		}
+	}
+	if len(types) == 0 && object["properties"] != nil {
+		types = []string{"object"}
+	}
+	if len(types) != 1 {
+		return schemaType{name: "interface{}", kind: anyKind}
+	}
+
+	resolved := schemaType{kind: scalarKind, nullable: nullable}
+	switch types[0] {
+	case "object":
+		properties, ok := object["properties"].(map[string]interface{})
+		if ok {
+			if !reserved {
+				name = g.uniqueName(name)
+			}
+			resolved.name, resolved.kind = name, structKind
+			g.pending = append(g.pending, func() { g.generateStruct(name, path, object, properties) })
+			break
+		}
+		elem := schemaType{name: "interface{}", kind: anyKind}
+		if additional, ok := object["additionalProperties"].(map[string]interface{}); ok {
+			elem = g.resolve(additional, name+"Value", path+"/additionalProperties")
+		}
+		resolved.name, resolved.kind, resolved.elem = "map[string]"+elem.declaration(false), mapKind, &elem
+	case "array":
+		elem := schemaType{name: "interface{}", kind: anyKind}
+		if items, ok := object["items"].(map[string]interface{}); ok {
+			elem = g.resolve(items, name+"Item", path+"/items")
+		}
+		resolved.name, resolved.kind, resolved.elem = "[]"+elem.declaration(false), sliceKind, &elem
+	case "string":
+		resolved.name = "string"
+		if object["format"] == "date-time" {
+			resolved.name = "time.Time"
+			g.usesTime = true
+		}
+	case "integer":
+		resolved.name = "int64"
+	case "number":
+		resolved.name = "float64"
+	case "boolean":
+		resolved.name = "bool"
+	default:
+		return schemaType{name: "interface{}", kind: anyKind}
+	}
+	return resolved
+}
+
+type structField struct {
+	key       string
+	name      string
+	fieldType schemaType
+	required  bool
+}
+
+func (g *typesGenerator) generateStruct(name string, path string, object map[string]interface{}, properties map[string]interface{}) {
+	required := make(map[string]bool)
+	requiredList, _ := object["required"].([]interface{})
+	for _, key := range requiredList {
+		if key, ok := key.(string); ok {
+			required[key] = true
+		}
+	}
+
+	fieldNames := make(map[string]bool)
+	fields := make(map[string]structField)
+	var keys, missingKeys []string
+	for _, key := range sortedKeys(properties) {
+		fieldName := identifier(key)
+		for i := 2; fieldNames[fieldName]; i++ {
+			fieldName = identifier(key) + strconv.Itoa(i)
+		}
+		fieldNames[fieldName] = true
+		field := structField{key: key, name: fieldName, fieldType: g.resolve(properties[key], name+identifier(key), path+"/properties/"+strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)), required: required[key]}
+		if field.required {
+			missingKeys = append(missingKeys, key)
+		}
+		fields[key] = field
+		keys = append(keys, key)
+	}
+
+	//Dependencies apply to present keys, null ones included, so UnmarshalJSON records the
+	//keys they name. Values built in Go fall back to their fields.
+	var dependencyChecks [][2]string
+	presence := make(map[string]interface{})
+	dependencies, _ := object["dependencies"].(map[string]interface{})
+	for _, key := range sortedKeys(dependencies) {
+		dependents, isList := dependencies[key].([]interface{})
+		if !isList {
+			continue //The schema form is left to the schema
+		}
+		for _, dependent := range dependents {
+			dependent, _ := dependent.(string)
+			if fields[dependent].required {
+				continue
+			}
+			dependencyChecks = append(dependencyChecks, [2]string{key, dependent})
+			presence[dependent] = true
+			if !fields[key].required {
+				presence[key] = true
+			}
+		}
+	}
+	presentKeys := sortedKeys(presence)
+
+	location := path
+	if location == "" {
+		location = "/"
+	}
+	g.printf("// %v is the object of the schema at %v.\n", name, location)
+	g.printf("type %v struct {\n", name)
+	for _, key := range keys {
+		field := fields[key]
+		tag := key
+		if !field.required {
+			tag += ",omitempty"
+		}
+		g.printf("%v %v `json:%q`\n", field.name, field.fieldType.declaration(!field.required), tag)
+	}
+	if len(missingKeys) > 0 || len(presentKeys) > 0 {
+		g.printf("\n")
+	}
+	if len(missingKeys) > 0 {
+		g.printf("missing []string\n")
+	}
+	if len(presentKeys) > 0 {
+		g.printf("present map[string]bool\n")
+	}
+	g.printf("}\n\n")
+
+	if len(missingKeys) > 0 || len(presentKeys) > 0 {
+		g.usesJSON = true
+		g.printf("// UnmarshalJSON records the keys of data that Validate checks.\n")
+		g.printf("func (v *%v) UnmarshalJSON(data []byte) error {\n", name)
+		g.printf("type plain %v\n", name)
+		g.printf("var keys map[string]json.RawMessage\n")
+		g.printf("if err := json.Unmarshal(data, &keys); err != nil {\nreturn err\n}\n")
+		g.printf("if err := json.Unmarshal(data, (*plain)(v)); err != nil {\nreturn err\n}\n")
+		if len(missingKeys) > 0 {
+			g.printf("v.missing = nil\n")
+			g.printf("for _, key := range %#v {\n", missingKeys)
+			g.printf("if _, ok := keys[key]; !ok {\nv.missing = append(v.missing, key)\n}\n}\n")
+		}
+		if len(presentKeys) > 0 {
+			g.printf("v.present = make(map[string]bool)\n")
+			g.printf("for _, key := range %#v {\n", presentKeys)
+			g.printf("if _, ok := keys[key]; ok {\nv.present[key] = true\n}\n}\n")
+		}
+		g.printf("return nil\n}\n\n")
+	}
+
+	g.printf("// Validate checks the required and dependencies keywords of the schema.\n")
+	g.printf("func (v *%v) Validate() error {\n", name)
+	if len(missingKeys) > 0 {
+		g.usesFmt = true
+		g.printf("if len(v.missing) > 0 {\nreturn fmt.Errorf(\"%%v is required\", v.missing[0])\n}\n")
+	}
+
+	for _, check := range dependencyChecks {
+		key, dependent := check[0], check[1]
+		var conditions []string
+		if !fields[key].required {
+			conditions = append(conditions, presentCondition(key, fields))
+		}
+		conditions = append(conditions, "!"+presentCondition(dependent, fields))
+		g.usesFmt = true
+		g.printf("if %v {\nreturn fmt.Errorf(%q)\n}\n", strings.Join(conditions, " && "), key+" requires "+dependent)
+	}
+
+	for _, key := range keys {
+		field := fields[key]
+		if field.fieldType.validates() {
+			g.usesFmt = true
+			g.validateValue("v."+field.name, field.fieldType, field.fieldType.declaration(!field.required)[0] == '*', strings.Replace(key, "%", "%%", -1), nil, 0)
+		}
+	}
+	g.printf("return nil\n}\n\n")
+}
+
+// validateValue calls Validate on the structs of a value, prefixing the errors with the
+// key, a format whose verbs take the indexes and keys of args.
+func (g *typesGenerator) validateValue(expression string, t schemaType, pointer bool, key string, args []string, depth int) {
+	switch t.kind {
+	case structKind:
+		if pointer {
+			g.printf("if %v != nil {\n", expression)
+		}
+		g.printf("if err := %v.Validate(); err != nil {\n", expression)
+		g.printf("return fmt.Errorf(%v)\n}\n", strings.Join(append([]string{strconv.Quote(key + ".%v")}, append(args, "err")...), ", "))
+		if pointer {
+			g.printf("}\n")
+		}
+	case sliceKind, mapKind:
+		index, item := fmt.Sprintf("i%v", depth), fmt.Sprintf("item%v", depth)
+		if t.kind == mapKind {
+			index = fmt.Sprintf("key%v", depth)
+		}
+		g.printf("for %v, %v := range %v {\n", index, item, expression)
+		g.validateValue(item, *t.elem, t.elem.declaration(false)[0] == '*', key+".%v", append(append([]string{}, args...), index), depth+1)
+		g.printf("}\n")
+	}
+}
+
+// presentCondition is the expression telling whether key is in the object, from the
+// decoded keys or from its field.
+func presentCondition(key string, fields map[string]structField) string {
+	if field, declared := fields[key]; declared {
+		return fmt.Sprintf("(v.present[%q] || v.%v != nil)", key, field.name)
+	}
+	return fmt.Sprintf("v.present[%q]", key)
+}
@@ -0,0 +1,93 @@
+package gen
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateTypesMatchesCommittedTypes(t *testing.T) {
+	generated := map[string]struct{ schemaPath, typeName string }{
+		"shipmentschema":   {"../schema.json", "Shipment"},
+		"dependenciestest": {"../generated/dependenciestest/testdata/schema.json", "Document"},
+	}
+	for packageName, generate := range generated {
+		schema, err := ioutil.ReadFile(generate.schemaPath)
+		if err != nil {
+			t.Fatalf("Error reading schema %v", err)
+		}
+
+		source, err := GenerateTypes(schema, packageName, generate.typeName, filepath.Base(generate.schemaPath))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		committed, err := ioutil.ReadFile("../generated/" + packageName + "/types.go")
+		if err != nil {
+			t.Fatalf("Error reading generated file %v", err)
+		}
+		if !bytes.Equal(source, committed) {
+			t.Errorf("jschema/generated/%v is stale, run go generate ./jschema/generated/...", packageName)
+		}
+	}
+}
+
+func TestGenerateTypes(t *testing.T) {
+	source, err := GenerateTypes([]byte(`{
+		"type": "object",
+		"required": ["tags", "node", "3pl"],
+		"properties": {
+			"tags": {"type": "array", "items": {"$ref": "#/definitions/tag"}},
+			"by_name": {"type": "object", "additionalProperties": {"$ref": "#/definitions/tag"}},
+			"node": {"$ref": "#/definitions/node"},
+			"3pl": {"type": ["string", "null"]},
+			"score": {"type": "number"},
+			"active": {"type": "boolean"},
+			"any": {},
+			"100%": {"type": "integer"}
+		},
+		"dependencies": {"score": ["active", "unknown"], "active": {"required": ["score"]}},
+		"definitions": {
+			"tag": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]},
+			"node": {"properties": {"children": {"type": "array", "items": {"$ref": "#/definitions/node"}}}}
+		}
+	}`), "types", "Document", "test.json")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "types.go", source, 0); err != nil {
+		t.Errorf("generated code does not parse: %v", err)
+	}
+	for _, expected := range []string{
+		"Tags   []Tag          `json:\"tags\"`",
+		"ByName map[string]Tag `json:\"by_name,omitempty\"`",
+		"Node   Node           `json:\"node\"`",
+		"M3pl   *string        `json:\"3pl\"`",
+		"Score  *float64",
+		"Any    interface{}",
+		"M100   *int64",
+		"Children []Node",
+		`[]string{"3pl", "node", "tags"}`,
+		"// Tag is the object of the schema at /definitions/tag.",
+		"present map[string]bool",
+		`[]string{"active", "score", "unknown"}`,
+		`if (v.present["score"] || v.Score != nil) && !(v.present["active"] || v.Active != nil) {`,
+		`if (v.present["score"] || v.Score != nil) && !v.present["unknown"] {
+		return fmt.Errorf("score requires unknown")`,
+		`for i0, item0 := range v.Tags {`,
+		`return fmt.Errorf("tags.%v.%v", i0, err)`,
+		`return fmt.Errorf("by_name.%v.%v", key0, err)`,
+		`return fmt.Errorf("node.%v", err)`,
+	} {
+		if !bytes.Contains(source, []byte(expected)) {
+			t.Errorf("expected %q in generated code", expected)
+		}
+	}
+
+	if _, err := GenerateTypes([]byte(`{"type": "string"}`), "types", "Document", "test.json"); err == nil {
+		t.Errorf("expected an error for a schema without properties")
+	}
+}
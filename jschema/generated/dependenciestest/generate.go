@@ -0,0 +1,6 @@
+// Package dependenciestest holds the types generated by jschemagen for a schema whose
+// dependencies name keys missing from its properties, or that can be null. It is kept in
+// the tree to prove that the generated Validate and the schema agree on them.
+package dependenciestest
+
+//go:generate go run ../../../cmd/jschemagen -schema testdata/schema.json -package dependenciestest -type Document -out types.go
@@ -0,0 +1,60 @@
+// Code generated by jschemagen from schema.json. DO NOT EDIT.
+
+package dependenciestest
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Document is the object of the schema at /.
+type Document struct {
+	A  *string  `json:"a,omitempty"`
+	C  *float64 `json:"c,omitempty"`
+	Id string   `json:"id"`
+
+	missing []string
+	present map[string]bool
+}
+
+// UnmarshalJSON records the keys of data that Validate checks.
+func (v *Document) UnmarshalJSON(data []byte) error {
+	type plain Document
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*plain)(v)); err != nil {
+		return err
+	}
+	v.missing = nil
+	for _, key := range []string{"id"} {
+		if _, ok := keys[key]; !ok {
+			v.missing = append(v.missing, key)
+		}
+	}
+	v.present = make(map[string]bool)
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if _, ok := keys[key]; ok {
+			v.present[key] = true
+		}
+	}
+	return nil
+}
+
+// Validate checks the required and dependencies keywords of the schema.
+func (v *Document) Validate() error {
+	if len(v.missing) > 0 {
+		return fmt.Errorf("%v is required", v.missing[0])
+	}
+	if (v.present["a"] || v.A != nil) && !v.present["b"] {
+		return fmt.Errorf("a requires b")
+	}
+	if (v.present["c"] || v.C != nil) && !(v.present["a"] || v.A != nil) {
+		return fmt.Errorf("c requires a")
+	}
+	if !v.present["d"] {
+		return fmt.Errorf("id requires d")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package dependenciestest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mercadolibre/jsonschema_test/jschema"
+)
+
+func TestValidateAgreesWithSchema(t *testing.T) {
+	schema, err := jschema.NewRegistry("testdata").Get("schema.json")
+	if err != nil {
+		t.Fatalf("Error reading schema %v", err)
+	}
+
+	documents := []string{
+		`{"id": "1"}`,
+		`{"id": "1", "d": 1}`,
+		`{"id": "1", "d": 1, "a": "x", "b": 1}`,
+		`{"id": "1", "d": 1, "a": "x"}`,
+		`{"id": "1", "d": 1, "a": null, "b": null}`,
+		`{"id": "1", "d": 1, "a": null}`,
+		`{"id": "1", "d": 1, "c": 1}`,
+		`{"id": "1", "d": 1, "c": 1, "a": null, "b": 2}`,
+		`{"id": "1", "d": 1, "b": 1}`,
+	}
+	for _, doc := range documents {
+		var document Document
+		if err := json.Unmarshal([]byte(doc), &document); err != nil {
+			t.Fatalf("%s: unexpected error %v", doc, err)
+		}
+		typedErr := document.Validate()
+		schemaValid, schemaErr := jschema.ValidateCompiledBytes([]byte(doc), schema)
+		if (typedErr == nil) != schemaValid {
+			t.Errorf("%s: Validate says %v, the schema says %v", doc, typedErr, schemaErr)
+		}
+	}
+}
+
+func TestValidateBuiltValues(t *testing.T) {
+	value := "x"
+	document := Document{Id: "1", A: &value}
+	if err := document.Validate(); err == nil || err.Error() != "a requires b" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,5 @@
+// Package shipmentschema holds the types generated by jschemagen for jschema/schema.json.
+// It is kept in the tree to prove that the generated Validate and the schema agree.
+package shipmentschema
+
+//go:generate go run ../../../cmd/jschemagen -schema ../../schema.json -package shipmentschema -type Shipment -out types.go
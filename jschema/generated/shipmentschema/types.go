@@ -0,0 +1,171 @@
+// Code generated by jschemagen from schema.json. DO NOT EDIT.
+
+package shipmentschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Shipment is the object of the schema at /.
+type Shipment struct {
+	Entity  string          `json:"entity"`
+	Id      string          `json:"id"`
+	Metrics ShipmentMetrics `json:"metrics"`
+	Version string          `json:"version"`
+
+	missing []string
+}
+
+// UnmarshalJSON records the keys of data that Validate checks.
+func (v *Shipment) UnmarshalJSON(data []byte) error {
+	type plain Shipment
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*plain)(v)); err != nil {
+		return err
+	}
+	v.missing = nil
+	for _, key := range []string{"entity", "id", "metrics", "version"} {
+		if _, ok := keys[key]; !ok {
+			v.missing = append(v.missing, key)
+		}
+	}
+	return nil
+}
+
+// Validate checks the required and dependencies keywords of the schema.
+func (v *Shipment) Validate() error {
+	if len(v.missing) > 0 {
+		return fmt.Errorf("%v is required", v.missing[0])
+	}
+	if err := v.Metrics.Validate(); err != nil {
+		return fmt.Errorf("metrics.%v", err)
+	}
+	return nil
+}
+
+// ShipmentMetrics is the object of the schema at /properties/metrics.
+type ShipmentMetrics struct {
+	HandlingTime ShipmentMetricsHandlingTime `json:"handling_time"`
+	LeadTime     ShipmentMetricsLeadTime     `json:"lead_time"`
+
+	missing []string
+}
+
+// UnmarshalJSON records the keys of data that Validate checks.
+func (v *ShipmentMetrics) UnmarshalJSON(data []byte) error {
+	type plain ShipmentMetrics
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*plain)(v)); err != nil {
+		return err
+	}
+	v.missing = nil
+	for _, key := range []string{"handling_time", "lead_time"} {
+		if _, ok := keys[key]; !ok {
+			v.missing = append(v.missing, key)
+		}
+	}
+	return nil
+}
+
+// Validate checks the required and dependencies keywords of the schema.
+func (v *ShipmentMetrics) Validate() error {
+	if len(v.missing) > 0 {
+		return fmt.Errorf("%v is required", v.missing[0])
+	}
+	if err := v.HandlingTime.Validate(); err != nil {
+		return fmt.Errorf("handling_time.%v", err)
+	}
+	if err := v.LeadTime.Validate(); err != nil {
+		return fmt.Errorf("lead_time.%v", err)
+	}
+	return nil
+}
+
+// ShipmentMetricsHandlingTime is the object of the schema at /properties/metrics/properties/handling_time.
+type ShipmentMetricsHandlingTime struct {
+	DateFrom             time.Time `json:"date_from"`
+	EstimatedDays        *int64    `json:"estimated_days,omitempty"`
+	EstimatedWorkingDays *int64    `json:"estimated_working_days,omitempty"`
+
+	missing []string
+	present map[string]bool
+}
+
+// UnmarshalJSON records the keys of data that Validate checks.
+func (v *ShipmentMetricsHandlingTime) UnmarshalJSON(data []byte) error {
+	type plain ShipmentMetricsHandlingTime
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*plain)(v)); err != nil {
+		return err
+	}
+	v.missing = nil
+	for _, key := range []string{"date_from"} {
+		if _, ok := keys[key]; !ok {
+			v.missing = append(v.missing, key)
+		}
+	}
+	v.present = make(map[string]bool)
+	for _, key := range []string{"estimated_days", "estimated_working_days"} {
+		if _, ok := keys[key]; ok {
+			v.present[key] = true
+		}
+	}
+	return nil
+}
+
+// Validate checks the required and dependencies keywords of the schema.
+func (v *ShipmentMetricsHandlingTime) Validate() error {
+	if len(v.missing) > 0 {
+		return fmt.Errorf("%v is required", v.missing[0])
+	}
+	if (v.present["estimated_days"] || v.EstimatedDays != nil) && !(v.present["estimated_working_days"] || v.EstimatedWorkingDays != nil) {
+		return fmt.Errorf("estimated_days requires estimated_working_days")
+	}
+	return nil
+}
+
+// ShipmentMetricsLeadTime is the object of the schema at /properties/metrics/properties/lead_time.
+type ShipmentMetricsLeadTime struct {
+	EstimatedDays      int64 `json:"estimated_days"`
+	ShippingOffsetDays int64 `json:"shipping_offset_days"`
+
+	missing []string
+}
+
+// UnmarshalJSON records the keys of data that Validate checks.
+func (v *ShipmentMetricsLeadTime) UnmarshalJSON(data []byte) error {
+	type plain ShipmentMetricsLeadTime
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*plain)(v)); err != nil {
+		return err
+	}
+	v.missing = nil
+	for _, key := range []string{"estimated_days", "shipping_offset_days"} {
+		if _, ok := keys[key]; !ok {
+			v.missing = append(v.missing, key)
+		}
+	}
+	return nil
+}
+
+// Validate checks the required and dependencies keywords of the schema.
+func (v *ShipmentMetricsLeadTime) Validate() error {
+	if len(v.missing) > 0 {
+		return fmt.Errorf("%v is required", v.missing[0])
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package shipmentschema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/mercadolibre/jsonschema_test/jschema"
+)
+
+// TestValidateAgreesWithSchema removes every key of document.json, one at a time, and
+// adds the optional keys of handling_time, comparing Validate with the schema.
+func TestValidateAgreesWithSchema(t *testing.T) {
+	content, err := ioutil.ReadFile("../../../document.json")
+	if err != nil {
+		t.Fatalf("Error reading document %v", err)
+	}
+	schema, err := jschema.NewRegistry("../..").Get("schema.json")
+	if err != nil {
+		t.Fatalf("Error reading schema %v", err)
+	}
+
+	var documents [][]byte
+	var walk func(object map[string]interface{})
+	var document map[string]interface{}
+	json.Unmarshal(content, &document)
+	walk = func(object map[string]interface{}) {
+		for key, value := range object {
+			delete(object, key)
+			mutated, _ := json.Marshal(document)
+			documents = append(documents, mutated)
+			object[key] = value
+			if child, isMap := value.(map[string]interface{}); isMap {
+				walk(child)
+			}
+		}
+	}
+	walk(document)
+	handlingTime := document["metrics"].(map[string]interface{})["handling_time"].(map[string]interface{})
+	for _, optional := range []map[string]interface{}{{"estimated_days": 1}, {"estimated_working_days": 1}, {"estimated_days": 1, "estimated_working_days": 2}} {
+		for key, value := range optional {
+			handlingTime[key] = value
+		}
+		mutated, _ := json.Marshal(document)
+		documents = append(documents, mutated)
+		delete(handlingTime, "estimated_days")
+		delete(handlingTime, "estimated_working_days")
+	}
+	documents = append(documents, content)
+
+	for _, doc := range documents {
+		var shipment Shipment
+		if err := json.Unmarshal(doc, &shipment); err != nil {
+			t.Fatalf("%s: unexpected error %v", doc, err)
+		}
+		typedErr := shipment.Validate()
+		schemaValid, schemaErr := jschema.ValidateCompiledBytes(doc, schema)
+		if (typedErr == nil) != schemaValid {
+			t.Errorf("%s: Validate says %v, the schema says %v", doc, typedErr, schemaErr)
+		}
+	}
+}
+
+func TestValidateBuiltValues(t *testing.T) {
+	days := int64(2)
+	shipment := Shipment{Entity: "SHIPMENT_TEST", Id: "1", Version: "0.0.1"}
+	shipment.Metrics.HandlingTime.EstimatedDays = &days
+	if err := shipment.Validate(); err == nil || err.Error() != "metrics.handling_time.estimated_days requires estimated_working_days" {
+		t.Errorf("unexpected error %v", err)
+	}
+	shipment.Metrics.HandlingTime.EstimatedWorkingDays = &days
+	if err := shipment.Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
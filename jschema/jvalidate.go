@@ -2,52 +2,60 @@ package jschema
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/xeipuuv/gojsonschema"
 )
 
 func Validate() (bool, error) {
-	return validateDocSch("file://./document.json", "file://./jschema/schema.json")
+	schema, err := Compile(gojsonschema.NewReferenceLoader("file://./jschema/schema.json"))
+	if err != nil {
+		return false, errors.New("Error reading schema " + err.Error())
+	}
+	return validateLoader(gojsonschema.NewReferenceLoader("file://./document.json"), schema)
 }
 
+// ValidateBytes returns a *ValidationError listing every failure when the document is not
+// valid. The schema document is unknown, so the failures have no schema paths.
 func ValidateBytes(doc []byte, schema *gojsonschema.Schema) (bool, error) {
 	documentLoader := gojsonschema.NewBytesLoader(doc)
-	return validateLoader(documentLoader, schema)
+	return validateLoader(documentLoader, &Schema{Schema: schema})
 }
 
 func ValidateDoc(doc string, schema *gojsonschema.Schema) (bool, error) {
 
 	documentLoader := gojsonschema.NewReferenceLoader(doc)
-	return validateLoader(documentLoader, schema)
+	return validateLoader(documentLoader, &Schema{Schema: schema})
 }
 
-func validateLoader(loader gojsonschema.JSONLoader, schema *gojsonschema.Schema) (bool, error) {
+// ValidateCompiledBytes behaves like ValidateBytes, the failures locating their keyword
+// in the schema document.
+func ValidateCompiledBytes(doc []byte, schema *Schema) (bool, error) {
+	return validateLoader(gojsonschema.NewBytesLoader(doc), schema)
+}
+
+// CheckBytes returns the result of validating the document, the error is only set when
+// the document can't be read.
+func CheckBytes(doc []byte, schema *Schema) (Result, error) {
+	return checkLoader(gojsonschema.NewBytesLoader(doc), schema)
+}
 
+func checkLoader(loader gojsonschema.JSONLoader, schema *Schema) (Result, error) {
 	result, err := schema.Validate(loader)
 	if err != nil {
-		return false, errors.New("Error reading document " + err.Error())
-	}
-
-	if result.Valid() {
-		return true, nil
-	} else {
-		fmt.Printf("The document is not valid. see errors :\n")
-		errorStr := ""
-		for _, desc := range result.Errors() {
-			errorStr = errorStr + fmt.Sprintf("- %s\n", desc)
-		}
-		return false, errors.New(errorStr)
+		return Result{}, errors.New("Error reading document " + err.Error())
 	}
+	return newResult(result, schema), nil
 }
 
-func validateDocSch(doc string, sch string) (bool, error) {
+func validateLoader(loader gojsonschema.JSONLoader, schema *Schema) (bool, error) {
 
-	schemaLoader := gojsonschema.NewReferenceLoader(sch)
-	schema, err := gojsonschema.NewSchema(schemaLoader)
+	result, err := checkLoader(loader, schema)
 	if err != nil {
-		return false, errors.New("Error reading schema ")
+		return false, err
 	}
 
-	return ValidateDoc(doc, schema)
+	if result.Valid {
+		return true, nil
+	}
+	return false, &ValidationError{Failures: result.Failures}
 }
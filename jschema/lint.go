@@ -0,0 +1,266 @@
+package jschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// LintIssue is a problem of a schema file, Path locates the Keyword in it.
+type LintIssue struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+func (issue LintIssue) String() string {
+	path := issue.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%v %v: %v", path, issue.Keyword, issue.Message)
+}
+
+// DefaultDraft is the meta-schema of the schemas without $schema.
+const DefaultDraft = "http://json-schema.org/draft-07/schema#"
+
+// metaSchemas are the drafts known by gojsonschema, compiled on first use.
+var metaSchemas = struct {
+	sync.Mutex
+	compiled map[string]*Schema
+}{compiled: make(map[string]*Schema)}
+
+func metaSchema(draft string) (*Schema, error) {
+	metaSchemas.Lock()
+	defer metaSchemas.Unlock()
+	if schema, ok := metaSchemas.compiled[draft]; ok {
+		return schema, nil
+	}
+	schema, err := Compile(gojsonschema.NewReferenceLoader(draft))
+	if err != nil {
+		return nil, err
+	}
+	metaSchemas.compiled[draft] = schema
+	return schema, nil
+}
+
+// Lint meta-validates a schema against its $schema draft, and looks for the mistakes the
+// meta-schema accepts: unknown keywords, required properties missing from properties,
+// dependencies that can't apply, formats without a registered checker and leaf formats
+// without their type. The error is only set for content that isn't JSON.
+func Lint(content []byte) ([]LintIssue, error) {
+	document, err := decode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	draft := DefaultDraft
+	object, _ := document.(map[string]interface{})
+	switch declared, _ := object["$schema"].(string); {
+	case declared == "":
+		issues = append(issues, LintIssue{"", "$schema", "missing, checked as " + DefaultDraft})
+	case metaSchemaDraft(declared) == "":
+		issues = append(issues, LintIssue{"", "$schema", fmt.Sprintf("unknown draft %v, checked as %v", declared, DefaultDraft)})
+	default:
+		draft = metaSchemaDraft(declared)
+	}
+
+	meta, err := metaSchema(draft)
+	if err != nil {
+		return nil, err
+	}
+	result, err := CheckBytes(content, meta)
+	if err != nil {
+		return nil, err
+	}
+	for _, failure := range result.Failures {
+		if failure.Keyword == "allOf" {
+			continue //The failures of the branches are reported too
+		}
+		issues = append(issues, LintIssue{failure.InstancePath, failure.Keyword, failure.Message})
+	}
+
+	properties, _ := meta.Document.(map[string]interface{})["properties"].(map[string]interface{})
+	linter := schemaLinter{keywords: properties, issues: issues}
+	linter.lint(document, "")
+
+	sort.SliceStable(linter.issues, func(i, j int) bool {
+		return linter.issues[i].Path < linter.issues[j].Path
+	})
+	return linter.issues, nil
+}
+
+// metaSchemaDraft returns the known draft URL of a $schema, with or without fragment.
+func metaSchemaDraft(declared string) string {
+	for _, version := range []string{"04", "06", "07"} {
+		draft := "http://json-schema.org/draft-" + version + "/schema#"
+		if declared == draft || declared+"#" == draft {
+			return draft
+		}
+	}
+	return ""
+}
+
+type schemaLinter struct {
+	keywords map[string]interface{}
+	issues   []LintIssue
+}
+
+func (linter *schemaLinter) report(path string, keyword string, format string, args ...interface{}) {
+	linter.issues = append(linter.issues, LintIssue{path, keyword, fmt.Sprintf(format, args...)})
+}
+
+// lint checks a subschema and the subschemas of its applicators, the values of enum,
+// const, default and examples are not schemas.
+func (linter *schemaLinter) lint(node interface{}, path string) {
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for _, keyword := range sortedKeys(object) {
+		if _, known := linter.keywords[keyword]; !known && keyword != "$ref" {
+			if suggestion := closestKeyword(keyword, linter.keywords); suggestion != "" {
+				linter.report(path, keyword, "unknown keyword, did you mean %v?", suggestion)
+			} else {
+				linter.report(path, keyword, "unknown keyword")
+			}
+		}
+	}
+	if format, ok := object["format"].(string); ok && !gojsonschema.FormatCheckers.Has(format) {
+		linter.report(path, "format", "no checker registered for %v, every value passes", format)
+	} else if leafType, ok := LeafFormats[format]; ok && !onlyType(object["type"], bic.LeafFormatType(leafType)) {
+		linter.report(path, "format", "%v only checks strings and numbers, pair it with type %v", format, bic.LeafFormatType(leafType))
+	}
+	linter.lintProperties(object, path)
+
+	for _, keyword := range []string{"properties", "patternProperties", "definitions", "dependencies"} {
+		children, _ := object[keyword].(map[string]interface{})
+		for _, name := range sortedKeys(children) {
+			linter.lint(children[name], path+"/"+keyword+"/"+escape(name))
+		}
+	}
+	for _, keyword := range []string{"additionalProperties", "additionalItems", "items", "contains", "propertyNames", "not", "if", "then", "else"} {
+		linter.lint(object[keyword], path+"/"+keyword)
+	}
+	for _, keyword := range []string{"items", "allOf", "anyOf", "oneOf"} {
+		branches, _ := object[keyword].([]interface{})
+		for i, branch := range branches {
+			linter.lint(branch, fmt.Sprintf("%v/%v/%v", path, keyword, i))
+		}
+	}
+}
+
+// lintProperties checks the names used by required and dependencies against the
+// declared properties, when the schema declares them.
+func (linter *schemaLinter) lintProperties(object map[string]interface{}, path string) {
+	properties, ok := object["properties"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	patterns, _ := object["patternProperties"].(map[string]interface{})
+	closed := object["additionalProperties"] == false
+	declared := func(name string) bool {
+		if _, ok := properties[name]; ok {
+			return true
+		}
+		for pattern := range patterns {
+			if matched, _ := regexp.MatchString(pattern, name); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range stringItems(object["required"]) {
+		if !declared(name) && closed {
+			linter.report(path, "required", "%v is required but additionalProperties forbids it", name)
+		} else if !declared(name) {
+			linter.report(path, "required", "%v is required but not in properties", name)
+		}
+	}
+
+	dependencies, _ := object["dependencies"].(map[string]interface{})
+	for _, name := range sortedKeys(dependencies) {
+		dependencyPath := path + "/dependencies/" + escape(name)
+		if !declared(name) && closed {
+			linter.report(dependencyPath, "dependencies", "unreachable, additionalProperties forbids %v", name)
+			continue
+		} else if !declared(name) {
+			linter.report(dependencyPath, "dependencies", "%v is not in properties", name)
+		}
+		for _, other := range stringItems(dependencies[name]) {
+			if !declared(other) && closed {
+				linter.report(dependencyPath, "dependencies", "%v requires %v, which additionalProperties forbids", name, other)
+			}
+		}
+	}
+}
+
+// closestKeyword suggests a known keyword at most two edits away from an unknown one,
+// and closer than half its length.
+func closestKeyword(keyword string, keywords map[string]interface{}) string {
+	best, bestDistance := "", 3
+	for _, known := range sortedKeys(keywords) {
+		if distance := editDistance(strings.ToLower(keyword), strings.ToLower(known)); distance < bestDistance && 2*distance < len(keyword) {
+			best, bestDistance = known, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Damerau-Levenshtein distance with adjacent transpositions.
+func editDistance(a string, b string) int {
+	left, right := []rune(a), []rune(b)
+	distances := make([][]int, len(left)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(right)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+	for i := 1; i <= len(left); i++ {
+		for j := 1; j <= len(right); j++ {
+			cost := 1
+			if left[i-1] == right[j-1] {
+				cost = 0
+			}
+			distance := distances[i-1][j] + 1
+			if insertion := distances[i][j-1] + 1; insertion < distance {
+				distance = insertion
+			}
+			if substitution := distances[i-1][j-1] + cost; substitution < distance {
+				distance = substitution
+			}
+			if i > 1 && j > 1 && left[i-1] == right[j-2] && left[i-2] == right[j-1] && distances[i-2][j-2]+1 < distance {
+				distance = distances[i-2][j-2] + 1
+			}
+			distances[i][j] = distance
+		}
+	}
+	return distances[len(left)][len(right)]
+}
+
+// onlyType reports whether a type keyword only allows jsonType, or null.
+func onlyType(keyword interface{}, jsonType string) bool {
+	if name, ok := keyword.(string); ok {
+		return name == jsonType
+	}
+	names, ok := keyword.([]interface{})
+	if !ok || len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		if name != jsonType && name != "null" {
+			return false
+		}
+	}
+	return true
+}
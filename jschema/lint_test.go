@@ -0,0 +1,85 @@
+package jschema
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	content, err := ioutil.ReadFile("schema.json")
+	if err != nil {
+		t.Fatalf("Error reading schema %v", err)
+	}
+	if issues, err := Lint(content); err != nil || len(issues) != 0 {
+		t.Errorf("expected schema.json to be clean, got %v %v", issues, err)
+	}
+
+	issues, err := Lint([]byte(`{
+		"$schema": "http://json-schema.org/draft-04/schema#",
+		"type": "object",
+		"requried": ["entity"],
+		"properties": {
+			"entity": {"type": "string", "minLength": -1},
+			"day": {"type": "string", "format": "weekday"},
+			"when": {"format": "bic-date"},
+			"flag": {"type": ["number", "null"], "format": "boolean_number"},
+			"lead_time": {"type": "integer", "if": {"minimum": 1}},
+			"items": {"type": "array", "items": [{"$ref": "#/definitions/tag"}]}
+		},
+		"required": ["id", "x-extra"],
+		"patternProperties": {"^x-": {}},
+		"dependencies": {"day": ["lead_time"], "offset": ["day"]},
+		"definitions": {"tag": {"properties": {"name": {}}, "required": ["name"], "additionalProperties": false, "dependencies": {"other": ["name"], "name": ["other"]}}}
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var found []string
+	for _, issue := range issues {
+		found = append(found, issue.Path+" "+issue.Keyword)
+	}
+	expected := []string{
+		" requried",
+		" required",
+		"/definitions/tag/dependencies/name dependencies",
+		"/definitions/tag/dependencies/other dependencies",
+		"/dependencies/offset dependencies",
+		"/properties/day format",
+		"/properties/entity/minLength minimum",
+		"/properties/lead_time if",
+		"/properties/when format",
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v, got %v", expected, issues)
+	}
+	if issues[0].Message != "unknown keyword, did you mean required?" {
+		t.Errorf("unexpected suggestion %v", issues[0].Message)
+	}
+
+	if issues, _ := Lint([]byte(`{"type": "object"}`)); len(issues) != 1 || issues[0].Keyword != "$schema" {
+		t.Errorf("expected a missing $schema issue, got %v", issues)
+	}
+	if _, err := Lint([]byte(`{"type": `)); err == nil {
+		t.Errorf("expected an error for invalid JSON")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"requried", "required", 1},
+		{"propertes", "properties", 1},
+		{"minimum", "minimum", 0},
+		{"type", "", 4},
+		{"enmu", "enum", 1},
+		{"maxLen", "maxLength", 3},
+	}
+	for _, test := range tests {
+		if distance := editDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("%v %v: expected %v, got %v", test.a, test.b, test.distance, distance)
+		}
+	}
+}
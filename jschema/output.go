@@ -0,0 +1,170 @@
+package jschema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OutputFormat is one of the output formats of the JSON Schema 2019-09 and 2020-12
+// specifications.
+type OutputFormat string
+
+const (
+	OutputFlag     OutputFormat = "flag"     //Only the verdict
+	OutputBasic    OutputFormat = "basic"    //A flat list of failures
+	OutputDetailed OutputFormat = "detailed" //Failures nested by subschema, single child nodes collapsed
+	OutputVerbose  OutputFormat = "verbose"  //Failures nested by subschema, every node kept
+)
+
+// OutputUnit is a node of the basic, detailed and verbose formats. Only failed
+// keywords are reported, gojsonschema doesn't return the ones that passed.
+type OutputUnit struct {
+	Valid                   bool         `json:"valid"`
+	KeywordLocation         string       `json:"keywordLocation"`
+	AbsoluteKeywordLocation string       `json:"absoluteKeywordLocation,omitempty"`
+	InstanceLocation        string       `json:"instanceLocation"`
+	Error                   string       `json:"error,omitempty"`
+	Errors                  []OutputUnit `json:"errors,omitempty"`
+}
+
+// FlagOutput is the flag format, the verdict alone.
+type FlagOutput struct {
+	Valid bool `json:"valid"`
+}
+
+// Output renders the result in format, ready to be marshalled. Failures are sorted by
+// instance location, keyword location and message, so the output is stable.
+func (result Result) Output(format OutputFormat) (interface{}, error) {
+	failures := append([]Failure(nil), result.Failures...)
+	sort.SliceStable(failures, func(i, j int) bool {
+		if failures[i].InstancePath != failures[j].InstancePath {
+			return failures[i].InstancePath < failures[j].InstancePath
+		}
+		if failures[i].EvaluationPath != failures[j].EvaluationPath {
+			return failures[i].EvaluationPath < failures[j].EvaluationPath
+		}
+		return failures[i].Message < failures[j].Message
+	})
+
+	root := OutputUnit{Valid: result.Valid}
+	switch format {
+	case OutputFlag:
+		return FlagOutput{Valid: result.Valid}, nil
+	case OutputBasic:
+		for _, failure := range failures {
+			root.Errors = append(root.Errors, failureUnit(failure))
+		}
+		return root, nil
+	case OutputDetailed, OutputVerbose:
+		for _, failure := range failures {
+			root.insert(failure)
+		}
+		if format == OutputDetailed {
+			for i := range root.Errors {
+				root.Errors[i] = root.Errors[i].collapse()
+			}
+		}
+		return root, nil
+	}
+	return nil, fmt.Errorf("jschema: unknown output format %q, expected %v, %v, %v or %v", format, OutputFlag, OutputBasic, OutputDetailed, OutputVerbose)
+}
+
+func failureUnit(failure Failure) OutputUnit {
+	unit := OutputUnit{
+		KeywordLocation:  failure.EvaluationPath,
+		InstanceLocation: failure.InstancePath,
+		Error:            failure.Message,
+	}
+	if unit.KeywordLocation == "" {
+		unit.KeywordLocation = "/" + failure.Keyword //The schema document was not available
+	}
+	if failure.SchemaPath != failure.EvaluationPath {
+		unit.AbsoluteKeywordLocation = "#" + failure.SchemaPath
+	}
+	return unit
+}
+
+// insert adds the failure below the units of the subschemas it goes through.
+func (unit *OutputUnit) insert(failure Failure) {
+	node := unit
+	for _, subschema := range subschemas(failure) {
+		var child *OutputUnit
+		for i := range node.Errors {
+			existing := &node.Errors[i]
+			if existing.Error == "" && existing.KeywordLocation == subschema.KeywordLocation && existing.InstanceLocation == subschema.InstanceLocation {
+				child = existing
+				break
+			}
+		}
+		if child == nil {
+			node.Errors = append(node.Errors, subschema)
+			child = &node.Errors[len(node.Errors)-1]
+		}
+		node = child
+	}
+	node.Errors = append(node.Errors, failureUnit(failure))
+}
+
+// collapse replaces the units holding a single unit by that unit.
+func (unit OutputUnit) collapse() OutputUnit {
+	for i := range unit.Errors {
+		unit.Errors[i] = unit.Errors[i].collapse()
+	}
+	if len(unit.Errors) == 1 {
+		return unit.Errors[0]
+	}
+	return unit
+}
+
+// subschemas returns the units of the subschemas crossed by the evaluation path of a
+// failure, with the instance location each one applies to.
+func subschemas(failure Failure) []OutputUnit {
+	var segments, instance []string
+	if failure.EvaluationPath != "" {
+		segments = strings.Split(failure.EvaluationPath[1:], "/")
+	}
+	if failure.InstancePath != "" {
+		instance = strings.Split(failure.InstancePath[1:], "/")
+	}
+
+	var units []OutputUnit
+	consumed := 0
+	for i := 0; i < len(segments)-1; {
+		width, moves := 1, false
+		switch segments[i] {
+		case "properties", "patternProperties":
+			width, moves = 2, true
+		case "items":
+			moves = true
+			if _, err := strconv.Atoi(segments[i+1]); err == nil && i+2 < len(segments) {
+				width = 2
+			}
+		case "additionalProperties", "additionalItems":
+			moves = true
+		case "allOf", "anyOf", "oneOf", "definitions", "$defs":
+			width = 2
+		}
+		if i+width > len(segments)-1 {
+			break //The keyword itself
+		}
+		if moves && consumed < len(instance) {
+			consumed++
+		}
+		i += width
+		units = append(units, OutputUnit{
+			KeywordLocation:  "/" + strings.Join(segments[:i], "/"),
+			InstanceLocation: pointerOf(instance[:consumed]),
+		})
+	}
+	return units
+}
+
+// pointerOf joins tokens that are already escaped.
+func pointerOf(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(tokens, "/")
+}
@@ -0,0 +1,72 @@
+package jschema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func outputOf(t *testing.T, result Result, format OutputFormat) string {
+	output, err := result.Output(format)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	content, err := json.Marshal(output)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	return string(content)
+}
+
+func TestOutput(t *testing.T) {
+	schema, err := CompileBytes(resultSchema)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	result, err := CheckBytes([]byte(`{"metrics": {"lead_time/days": -1, "tags": ["a", 2]}}`), schema)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := map[OutputFormat]string{
+		OutputFlag: `{"valid":false}`,
+		OutputBasic: `{"valid":false,"keywordLocation":"","instanceLocation":"","errors":[` +
+			`{"valid":false,"keywordLocation":"/required","instanceLocation":"","error":"entity is required"},` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/lead_time~1days/$ref/minimum","absoluteKeywordLocation":"#/definitions/days/minimum","instanceLocation":"/metrics/lead_time~1days","error":"Must be greater than or equal to 0"},` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/tags/items/type","instanceLocation":"/metrics/tags/1","error":"Invalid type. Expected: string, given: integer"}]}`,
+		OutputDetailed: `{"valid":false,"keywordLocation":"","instanceLocation":"","errors":[` +
+			`{"valid":false,"keywordLocation":"/required","instanceLocation":"","error":"entity is required"},` +
+			`{"valid":false,"keywordLocation":"/properties/metrics","instanceLocation":"/metrics","errors":[` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/lead_time~1days/$ref/minimum","absoluteKeywordLocation":"#/definitions/days/minimum","instanceLocation":"/metrics/lead_time~1days","error":"Must be greater than or equal to 0"},` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/tags/items/type","instanceLocation":"/metrics/tags/1","error":"Invalid type. Expected: string, given: integer"}]}]}`,
+		OutputVerbose: `{"valid":false,"keywordLocation":"","instanceLocation":"","errors":[` +
+			`{"valid":false,"keywordLocation":"/required","instanceLocation":"","error":"entity is required"},` +
+			`{"valid":false,"keywordLocation":"/properties/metrics","instanceLocation":"/metrics","errors":[` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/lead_time~1days","instanceLocation":"/metrics/lead_time~1days","errors":[` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/lead_time~1days/$ref","instanceLocation":"/metrics/lead_time~1days","errors":[` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/lead_time~1days/$ref/minimum","absoluteKeywordLocation":"#/definitions/days/minimum","instanceLocation":"/metrics/lead_time~1days","error":"Must be greater than or equal to 0"}]}]},` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/tags","instanceLocation":"/metrics/tags","errors":[` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/tags/items","instanceLocation":"/metrics/tags/1","errors":[` +
+			`{"valid":false,"keywordLocation":"/properties/metrics/properties/tags/items/type","instanceLocation":"/metrics/tags/1","error":"Invalid type. Expected: string, given: integer"}]}]}]}]}`,
+	}
+
+	reversed := Result{Valid: result.Valid}
+	for i := len(result.Failures) - 1; i >= 0; i-- {
+		reversed.Failures = append(reversed.Failures, result.Failures[i])
+	}
+	for format, want := range expected {
+		if got := outputOf(t, result, format); got != want {
+			t.Errorf("%v:\nexpected %v\ngot      %v", format, want, got)
+		}
+		if got := outputOf(t, reversed, format); got != want {
+			t.Errorf("%v: expected the same output whatever the failure order, got %v", format, got)
+		}
+	}
+
+	valid, _ := CheckBytes([]byte(`{"entity": "SHIPMENT"}`), schema)
+	if got := outputOf(t, valid, OutputBasic); got != `{"valid":true,"keywordLocation":"","instanceLocation":""}` {
+		t.Errorf("unexpected output for a valid document %v", got)
+	}
+	if _, err := result.Output("compact"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
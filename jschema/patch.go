@@ -0,0 +1,483 @@
+package jschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is an operation of a patch. Merge patches are flattened into one
+// operation per member they add, replace or remove, numbered in key order. Path is the
+// pointer written by the operation, with "-" replaced by the index of the new item.
+type PatchOperation struct {
+	Index int    `json:"index"`
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+}
+
+func (operation PatchOperation) String() string {
+	if operation.From != "" {
+		return fmt.Sprintf("#%v %v %v from %v", operation.Index, operation.Op, operation.Path, operation.From)
+	}
+	return fmt.Sprintf("#%v %v %v", operation.Index, operation.Op, operation.Path)
+}
+
+// PatchFailure is a failure of the patched document with the operations that wrote the
+// failing value, or changed the content of the failing object or array. Failures
+// without operations were already in the current document.
+type PatchFailure struct {
+	Failure
+	Operations []PatchOperation `json:"operations,omitempty"`
+}
+
+func (failure PatchFailure) String() string {
+	if len(failure.Operations) == 0 {
+		return failure.Failure.String()
+	}
+	operations := make([]string, len(failure.Operations))
+	for i, operation := range failure.Operations {
+		operations[i] = operation.String()
+	}
+	return failure.Failure.String() + " (" + strings.Join(operations, ", ") + ")"
+}
+
+// PatchError is returned when the patched document doesn't follow the schema.
+type PatchError struct {
+	Failures []PatchFailure
+}
+
+func (err *PatchError) Error() string {
+	var text strings.Builder
+	for _, failure := range err.Failures {
+		text.WriteString("- " + failure.String() + "\n")
+	}
+	return text.String()
+}
+
+// OperationError is returned when an operation can't be applied to the document.
+type OperationError struct {
+	Operation PatchOperation
+	Reason    string
+}
+
+func (err *OperationError) Error() string {
+	return fmt.Sprintf("jschema: patch operation %v: %v", err.Operation, err.Reason)
+}
+
+// ValidateMergePatch applies an RFC 7396 merge patch to the current document and
+// validates the result, which is returned with sorted keys when valid.
+func ValidateMergePatch(current []byte, patch []byte, schema *Schema) ([]byte, error) {
+	document, err := decode(current)
+	if err != nil {
+		return nil, fmt.Errorf("jschema: reading the current document: %v", err)
+	}
+	members, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("jschema: reading the merge patch: %v", err)
+	}
+	var operations []PatchOperation
+	if !isObject(members) {
+		operations = append(operations, PatchOperation{Op: "replace"})
+	}
+	document = mergePatch(document, members, "", &operations)
+	return validatePatched(document, operations, schema)
+}
+
+// ValidateJSONPatch applies an RFC 6902 patch to the current document and validates the
+// result, which is returned with sorted keys when valid. Operations that can't be
+// applied, including failed tests, return an *OperationError.
+func ValidateJSONPatch(current []byte, patch []byte, schema *Schema) ([]byte, error) {
+	document, err := decode(current)
+	if err != nil {
+		return nil, fmt.Errorf("jschema: reading the current document: %v", err)
+	}
+	var steps []struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(patch, &steps); err != nil {
+		return nil, fmt.Errorf("jschema: reading the patch: %v", err)
+	}
+
+	operations := make([]PatchOperation, 0, len(steps))
+	for i, step := range steps {
+		operation := PatchOperation{Index: i, Op: step.Op}
+		if step.Path == nil {
+			return nil, &OperationError{operation, "missing path"}
+		}
+		operation.Path = *step.Path
+		var value interface{}
+		switch step.Op {
+		case "add", "replace", "test":
+			if step.Value == nil {
+				return nil, &OperationError{operation, "missing value"}
+			}
+			if value, err = decode(step.Value); err != nil {
+				return nil, &OperationError{operation, err.Error()}
+			}
+		case "move", "copy":
+			if step.From == nil {
+				return nil, &OperationError{operation, "missing from"}
+			}
+			operation.From = *step.From
+		case "remove":
+		default:
+			return nil, &OperationError{operation, "unknown operation"}
+		}
+
+		if document, operation.Path, err = applyOperation(document, operation, value); err != nil {
+			return nil, &OperationError{operation, err.Error()}
+		}
+		operations = append(operations, operation)
+	}
+	return validatePatched(document, operations, schema)
+}
+
+func validatePatched(document interface{}, operations []PatchOperation, schema *Schema) ([]byte, error) {
+	content, err := encode(document)
+	if err != nil {
+		return nil, err
+	}
+	result, err := CheckBytes(content, schema)
+	if err != nil {
+		return nil, err
+	}
+	if result.Valid {
+		return content, nil
+	}
+
+	failures := make([]PatchFailure, len(result.Failures))
+	for i, failure := range result.Failures {
+		failures[i] = PatchFailure{Failure: failure}
+		paths := []string{failure.InstancePath}
+		if failure.Keyword == "required" {
+			paths = missingProperties(failure, schema)
+		}
+		for _, operation := range operations {
+			for _, path := range paths {
+				if related(operation.Path, path) || (operation.Op == "move" && related(operation.From, path)) {
+					failures[i].Operations = append(failures[i].Operations, operation)
+					break
+				}
+			}
+		}
+	}
+	return nil, &PatchError{Failures: failures}
+}
+
+// missingProperties returns the pointers of the required properties missing from the
+// object of a required failure, so only the operations removing them are blamed.
+func missingProperties(failure Failure, schema *Schema) []string {
+	object, _ := failure.Value.(map[string]interface{})
+	var node interface{}
+	if schema.Document != nil && failure.SchemaPath != "" {
+		node = lookup(map[string]interface{}{"": schema.Document}, failure.SchemaPath)
+	}
+	required, _ := node.([]interface{})
+	var paths []string
+	for _, key := range required {
+		if key, ok := key.(string); ok {
+			if _, present := object[key]; !present {
+				paths = append(paths, failure.InstancePath+"/"+escape(key))
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return []string{failure.InstancePath}
+	}
+	return paths
+}
+
+// related tells whether one pointer is the other or contains it.
+func related(path string, other string) bool {
+	return path == other || strings.HasPrefix(other, path+"/") || strings.HasPrefix(path, other+"/")
+}
+
+func mergePatch(target interface{}, patch interface{}, path string, operations *[]PatchOperation) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return copyValue(patch)
+	}
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = make(map[string]interface{})
+	}
+
+	keys := make([]string, 0, len(members))
+	for key := range members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := path + "/" + escape(key)
+		current, present := object[key]
+		if members[key] == nil {
+			if present {
+				delete(object, key)
+				*operations = append(*operations, PatchOperation{Index: len(*operations), Op: "remove", Path: child})
+			}
+			continue
+		}
+		if _, merging := members[key].(map[string]interface{}); !merging || !isObject(current) {
+			op := "add"
+			if present {
+				op = "replace"
+			}
+			*operations = append(*operations, PatchOperation{Index: len(*operations), Op: op, Path: child})
+		}
+		object[key] = mergePatch(current, members[key], child, operations)
+	}
+	return object
+}
+
+func isObject(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+	return ok
+}
+
+// applyOperation applies one operation to the document and returns the document with
+// the pointer written by the operation.
+func applyOperation(document interface{}, operation PatchOperation, value interface{}) (interface{}, string, error) {
+	tokens, err := parsePointer(operation.Path)
+	if err != nil {
+		return document, operation.Path, err
+	}
+	switch operation.Op {
+	case "add":
+		return addValue(document, tokens, value, operation.Path)
+	case "remove":
+		document, _, err = removeValue(document, tokens)
+		return document, operation.Path, err
+	case "replace":
+		if len(tokens) == 0 {
+			return value, operation.Path, nil //Replacing the root replaces the whole document
+		}
+		if document, _, err = removeValue(document, tokens); err != nil {
+			return document, operation.Path, err
+		}
+		return addValue(document, tokens, value, operation.Path)
+	case "test":
+		current, err := getValue(document, tokens)
+		if err == nil && !equalValues(current, value) {
+			err = fmt.Errorf("the value is %v", current)
+		}
+		return document, operation.Path, err
+	}
+
+	from, err := parsePointer(operation.From)
+	if err != nil {
+		return document, operation.Path, err
+	}
+	if operation.Op == "move" {
+		if strings.HasPrefix(operation.Path, operation.From+"/") {
+			return document, operation.Path, fmt.Errorf("can't move %v into itself", operation.From)
+		}
+		document, value, err = removeValue(document, from)
+	} else {
+		value, err = getValue(document, from)
+		value = copyValue(value)
+	}
+	if err != nil {
+		return document, operation.Path, err
+	}
+	return addValue(document, tokens, value, operation.Path)
+}
+
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index lower than limit.
+func arrayIndex(token string, limit int) (int, error) {
+	if !arrayIndexPattern.MatchString(token) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index >= limit {
+		return 0, fmt.Errorf("array index %v out of range", token)
+	}
+	return index, nil
+}
+
+func getValue(node interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch parent := node.(type) {
+		case map[string]interface{}:
+			child, ok := parent[token]
+			if !ok {
+				return nil, fmt.Errorf("missing member %q", token)
+			}
+			node = child
+		case []interface{}:
+			index, err := arrayIndex(token, len(parent))
+			if err != nil {
+				return nil, err
+			}
+			node = parent[index]
+		default:
+			return nil, fmt.Errorf("%q is not in an object or array", token)
+		}
+	}
+	return node, nil
+}
+
+// addValue returns the node with value added, and path with a final "-" replaced by the
+// index of the appended item.
+func addValue(node interface{}, tokens []string, value interface{}, path string) (interface{}, string, error) {
+	if len(tokens) == 0 {
+		return value, path, nil
+	}
+	token := tokens[0]
+	switch parent := node.(type) {
+	case map[string]interface{}:
+		if len(tokens) == 1 {
+			parent[token] = value
+			return parent, path, nil
+		}
+		child, ok := parent[token]
+		if !ok {
+			return node, path, fmt.Errorf("missing member %q", token)
+		}
+		child, path, err := addValue(child, tokens[1:], value, path)
+		parent[token] = child
+		return parent, path, err
+	case []interface{}:
+		if len(tokens) == 1 {
+			index := len(parent)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(parent)+1); err != nil {
+					return node, path, err
+				}
+			} else {
+				path = strings.TrimSuffix(path, "-") + strconv.Itoa(index)
+			}
+			parent = append(parent, nil)
+			copy(parent[index+1:], parent[index:])
+			parent[index] = value
+			return parent, path, nil
+		}
+		index, err := arrayIndex(token, len(parent))
+		if err != nil {
+			return node, path, err
+		}
+		parent[index], path, err = addValue(parent[index], tokens[1:], value, path)
+		return parent, path, err
+	}
+	return node, path, fmt.Errorf("%q is not in an object or array", token)
+}
+
+// removeValue returns the node without the value at tokens, and the removed value.
+func removeValue(node interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return node, nil, fmt.Errorf("can't remove the whole document")
+	}
+	token := tokens[0]
+	switch parent := node.(type) {
+	case map[string]interface{}:
+		child, ok := parent[token]
+		if !ok {
+			return node, nil, fmt.Errorf("missing member %q", token)
+		}
+		if len(tokens) == 1 {
+			delete(parent, token)
+			return parent, child, nil
+		}
+		child, removed, err := removeValue(child, tokens[1:])
+		parent[token] = child
+		return parent, removed, err
+	case []interface{}:
+		index, err := arrayIndex(token, len(parent))
+		if err != nil {
+			return node, nil, err
+		}
+		if len(tokens) == 1 {
+			removed := parent[index]
+			return append(parent[:index], parent[index+1:]...), removed, nil
+		}
+		var removed interface{}
+		parent[index], removed, err = removeValue(parent[index], tokens[1:])
+		return parent, removed, err
+	}
+	return node, nil, fmt.Errorf("%q is not in an object or array", token)
+}
+
+// equalValues compares decoded JSON values, numbers by their value.
+func equalValues(value interface{}, other interface{}) bool {
+	switch value := value.(type) {
+	case json.Number:
+		number, ok := other.(json.Number)
+		if !ok {
+			return false
+		}
+		left, leftOk := new(big.Rat).SetString(string(value))
+		right, rightOk := new(big.Rat).SetString(string(number))
+		return leftOk && rightOk && left.Cmp(right) == 0
+	case map[string]interface{}:
+		object, ok := other.(map[string]interface{})
+		if !ok || len(object) != len(value) {
+			return false
+		}
+		for key, item := range value {
+			if otherItem, present := object[key]; !present || !equalValues(item, otherItem) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		items, ok := other.([]interface{})
+		if !ok || len(items) != len(value) {
+			return false
+		}
+		for i := range value {
+			if !equalValues(value[i], items[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(value, other)
+}
+
+// decode reads a JSON value keeping numbers as json.Number.
+func decode(content []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected content after the JSON value")
+	}
+	return value, nil
+}
+
+// encode writes a JSON value without escaping HTML characters.
+func encode(value interface{}) ([]byte, error) {
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(content.Bytes(), []byte("\n")), nil
+}
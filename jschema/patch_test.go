@@ -0,0 +1,135 @@
+package jschema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var patchSchema = []byte(`{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["entity", "id", "metrics"],
+	"properties": {
+		"entity": {"type": "string"},
+		"id": {"type": "string"},
+		"metrics": {
+			"type": "object",
+			"required": ["lead_time", "days"],
+			"properties": {
+				"lead_time": {"type": "number", "minimum": 0},
+				"days": {"type": "array", "items": {"type": "string"}}
+			}
+		}
+	}
+}`)
+
+var patchCurrent = []byte(`{"entity": "SHIPMENT", "id": "1", "metrics": {"lead_time": 2.50, "days": ["mon", "tue"]}}`)
+
+func TestValidateMergePatch(t *testing.T) {
+	schema, err := CompileBytes(patchSchema)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+
+	patched, err := ValidateMergePatch(patchCurrent, []byte(`{"metrics": {"lead_time": 3, "days": ["wed"]}, "notes": {"a": null, "b": "<b>"}}`), schema)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := `{"entity":"SHIPMENT","id":"1","metrics":{"days":["wed"],"lead_time":3},"notes":{"b":"<b>"}}`
+	if string(patched) != expected {
+		t.Errorf("expected %v, got %s", expected, patched)
+	}
+
+	_, err = ValidateMergePatch(patchCurrent, []byte(`{"id": null, "metrics": {"lead_time": -1, "days": null}, "entity": "ORDER"}`), schema)
+	var patchError *PatchError
+	if !errors.As(err, &patchError) {
+		t.Fatalf("expected a PatchError, got %v", err)
+	}
+	blamed := blamedOperations(patchError)
+	want := map[string][]PatchOperation{
+		"/required":                    {{Index: 1, Op: "remove", Path: "/id"}},
+		"/properties/metrics/required": {{Index: 2, Op: "remove", Path: "/metrics/days"}},
+		"/properties/metrics/properties/lead_time/minimum": {{Index: 3, Op: "replace", Path: "/metrics/lead_time"}},
+	}
+	if !reflect.DeepEqual(blamed, want) {
+		t.Errorf("expected operations %+v, got %+v", want, blamed)
+	}
+
+	if _, err := ValidateMergePatch(patchCurrent, []byte(`"replaced"`), schema); !errors.As(err, &patchError) || len(patchError.Failures[0].Operations) != 1 {
+		t.Errorf("expected the root replacement to be blamed, got %v", err)
+	}
+}
+
+func TestValidateJSONPatch(t *testing.T) {
+	schema, err := CompileBytes(patchSchema)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+
+	patched, err := ValidateJSONPatch(patchCurrent, []byte(`[
+		{"op": "test", "path": "/metrics/lead_time", "value": 2.5},
+		{"op": "add", "path": "/metrics/days/-", "value": "wed"},
+		{"op": "add", "path": "/metrics/days/0", "value": "sun"},
+		{"op": "remove", "path": "/metrics/days/1"},
+		{"op": "copy", "from": "/metrics", "path": "/previous"},
+		{"op": "move", "from": "/previous/lead_time", "path": "/lead_time"},
+		{"op": "replace", "path": "/id", "value": "2"}
+	]`), schema)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := `{"entity":"SHIPMENT","id":"2","lead_time":2.50,"metrics":{"days":["sun","tue","wed"],"lead_time":2.50},"previous":{"days":["sun","tue","wed"]}}`
+	if string(patched) != expected {
+		t.Errorf("expected %v, got %s", expected, patched)
+	}
+
+	_, err = ValidateJSONPatch(patchCurrent, []byte(`[
+		{"op": "add", "path": "/metrics/days/-", "value": 7},
+		{"op": "move", "from": "/metrics/lead_time", "path": "/lead_time"},
+		{"op": "add", "path": "/tags", "value": []}
+	]`), schema)
+	var patchError *PatchError
+	if !errors.As(err, &patchError) {
+		t.Fatalf("expected a PatchError, got %v", err)
+	}
+	blamed := blamedOperations(patchError)
+	want := map[string][]PatchOperation{
+		"/properties/metrics/required":                   {{Index: 1, Op: "move", Path: "/lead_time", From: "/metrics/lead_time"}},
+		"/properties/metrics/properties/days/items/type": {{Index: 0, Op: "add", Path: "/metrics/days/2"}},
+	}
+	if !reflect.DeepEqual(blamed, want) {
+		t.Errorf("expected operations %+v, got %+v", want, blamed)
+	}
+
+	replaced := `{"entity":"ORDER","id":"3","metrics":{"days":[],"lead_time":1}}`
+	if patched, err := ValidateJSONPatch(patchCurrent, []byte(`[{"op": "replace", "path": "", "value": `+replaced+`}]`), schema); err != nil || string(patched) != replaced {
+		t.Errorf("expected the replaced document %v, got %s (%v)", replaced, patched, err)
+	}
+
+	invalid := []string{
+		`[{"op": "test", "path": "/id", "value": "2"}]`,
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "add", "path": "/metrics/days/3", "value": "x"}]`,
+		`[{"op": "add", "path": "/metrics/days/01", "value": "x"}]`,
+		`[{"op": "replace", "path": "/metrics/days/-", "value": "x"}]`,
+		`[{"op": "move", "from": "/metrics", "path": "/metrics/other"}]`,
+		`[{"op": "add", "path": "/id"}]`,
+		`[{"op": "rename", "path": "/id"}]`,
+		`[{"op": "add", "path": "id", "value": "x"}]`,
+	}
+	for _, patch := range invalid {
+		var operationError *OperationError
+		if _, err := ValidateJSONPatch(patchCurrent, []byte(patch), schema); !errors.As(err, &operationError) || operationError.Operation.Index != 0 {
+			t.Errorf("%v: expected an OperationError, got %v", patch, err)
+		}
+	}
+}
+
+func blamedOperations(err *PatchError) map[string][]PatchOperation {
+	blamed := make(map[string][]PatchOperation)
+	for _, failure := range err.Failures {
+		blamed[failure.SchemaPath] = failure.Operations
+	}
+	return blamed
+}
@@ -0,0 +1,221 @@
+package jschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Registry compiles every .json schema under a root directory once and serves the
+// compiled schemas by path or $id. References are resolved offline: relative and
+// file references are read from the root, other URIs must be the $id of a schema of the
+// root. Schemas that fail to compile are left out and reported by Reload, Get returns
+// their error. It is safe for concurrent use, and Reload swaps the whole set at once.
+type Registry struct {
+	root      string
+	reloading sync.Mutex //Serializes the loads, so concurrent first calls load once
+	mutex     sync.RWMutex
+	schemas   map[string]*Schema
+	failures  map[string]error
+}
+
+// NewRegistry returns a registry of the schemas under root. A relative root is resolved
+// against the working directory once, here.
+func NewRegistry(root string) *Registry {
+	if absolute, err := filepath.Abs(root); err == nil {
+		root = absolute
+	}
+	return &Registry{root: root}
+}
+
+// LoadError lists the schemas of a registry that failed to compile, by path.
+type LoadError struct {
+	Failures map[string]error
+}
+
+func (err *LoadError) Error() string {
+	names := make([]string, 0, len(err.Failures))
+	for name := range err.Failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = err.Failures[name].Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Get returns the schema stored at a path of the root, with or without the .json
+// extension, or the schema whose $id is key. The schemas are loaded on the first call.
+func (registry *Registry) Get(key string) (*Schema, error) {
+	schemas, failures, err := registry.loaded()
+	if err != nil {
+		return nil, err
+	}
+
+	key = strings.TrimSuffix(key, "#")
+	if !strings.Contains(key, "://") {
+		key = path.Clean("/" + filepath.ToSlash(key))[1:]
+	}
+	for _, candidate := range []string{key, key + ".json"} {
+		if schema, ok := schemas[candidate]; ok {
+			return schema, nil
+		}
+		if err, ok := failures[candidate]; ok {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("jschema: schema %v not found in %v", key, registry.root)
+}
+
+// loaded returns the schemas and the failures, loading them when no load succeeded yet.
+func (registry *Registry) loaded() (map[string]*Schema, map[string]error, error) {
+	registry.mutex.RLock()
+	schemas, failures := registry.schemas, registry.failures
+	registry.mutex.RUnlock()
+	if schemas != nil {
+		return schemas, failures, nil
+	}
+
+	registry.reloading.Lock()
+	defer registry.reloading.Unlock()
+	registry.mutex.RLock()
+	schemas = registry.schemas
+	registry.mutex.RUnlock()
+	if schemas == nil {
+		if err := registry.reload(); err != nil {
+			if _, partial := err.(*LoadError); !partial {
+				return nil, nil, err
+			}
+		}
+	}
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return registry.schemas, registry.failures, nil
+}
+
+// Reload compiles again every schema of the root. When the root can't be walked the
+// loaded schemas are kept, otherwise they are replaced by the ones that compile and a
+// *LoadError lists the others.
+func (registry *Registry) Reload() error {
+	registry.reloading.Lock()
+	defer registry.reloading.Unlock()
+	return registry.reload()
+}
+
+func (registry *Registry) reload() error {
+	failures := make(map[string]error)
+	documents := make(map[string]interface{})
+	err := filepath.Walk(registry.root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(file) != ".json" {
+			return err
+		}
+		relative, _ := filepath.Rel(registry.root, file)
+		name := filepath.ToSlash(relative)
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			failures[name] = fmt.Errorf("jschema: %v: %v", name, err)
+			return nil
+		}
+		var document interface{}
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		if err := decoder.Decode(&document); err != nil {
+			failures[name] = fmt.Errorf("jschema: %v: %v", name, err)
+			return nil
+		}
+		documents[name] = document
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(documents))
+	for name := range documents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	//Schemas with an $id can be referenced by it without fetching them
+	loader := gojsonschema.NewSchemaLoader()
+	for _, name := range names {
+		if schemaID(documents[name]) != "" {
+			if err := loader.AddSchemas(gojsonschema.NewGoLoader(documents[name])); err != nil {
+				failures[name] = fmt.Errorf("jschema: %v: %v", name, err)
+			}
+		}
+	}
+
+	filesystem := http.Dir(registry.root)
+	schemas := make(map[string]*Schema, 2*len(documents))
+	for _, name := range names {
+		if failures[name] != nil {
+			continue
+		}
+		compiled, err := loader.Compile(offlineLoader{gojsonschema.NewReferenceLoaderFileSystem("file:///"+name, filesystem), filesystem})
+		if err != nil {
+			failures[name] = fmt.Errorf("jschema: %v: %v", name, err)
+			continue
+		}
+		schema := &Schema{Schema: compiled, Document: documents[name]}
+		schemas[name] = schema
+		if id := schemaID(documents[name]); id != "" {
+			schemas[id] = schema
+		}
+	}
+
+	registry.mutex.Lock()
+	registry.schemas = schemas
+	registry.failures = failures
+	registry.mutex.Unlock()
+	if len(failures) > 0 {
+		return &LoadError{Failures: failures}
+	}
+	return nil
+}
+
+// schemaID returns the $id of a schema, or the id of draft-04 schemas, without the
+// empty fragment.
+func schemaID(document interface{}) string {
+	object, _ := document.(map[string]interface{})
+	id, _ := object["$id"].(string)
+	if id == "" {
+		id, _ = object["id"].(string)
+	}
+	return strings.TrimSuffix(id, "#")
+}
+
+// offlineLoader reads references from the registry root and refuses to fetch remote
+// documents. The draft meta schemas are embedded in gojsonschema, they are allowed.
+type offlineLoader struct {
+	gojsonschema.JSONLoader
+	filesystem http.FileSystem
+}
+
+func (loader offlineLoader) New(source string) gojsonschema.JSONLoader {
+	return offlineLoader{gojsonschema.NewReferenceLoaderFileSystem(source, loader.filesystem), loader.filesystem}
+}
+
+func (loader offlineLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return loader
+}
+
+func (loader offlineLoader) LoadJSON() (interface{}, error) {
+	source, _ := loader.JsonSource().(string)
+	if !strings.HasPrefix(source, "file://") && !strings.HasPrefix(source, "http://json-schema.org/") {
+		return nil, fmt.Errorf("jschema: remote reference %v is not the $id of a registered schema", source)
+	}
+	return loader.JSONLoader.LoadJSON()
+}
@@ -0,0 +1,200 @@
+package jschema
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var registryFiles = map[string]string{
+	"common/days.json": `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"$id": "https://schemas.example.com/days.json",
+		"definitions": {"days": {"type": "integer", "minimum": 0}}
+	}`,
+	"shipment.json": `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"properties": {
+			"estimated_days": {"$ref": "common/days.json#/definitions/days"},
+			"offset_days": {"$ref": "https://schemas.example.com/days.json#/definitions/days"}
+		}
+	}`,
+}
+
+func writeRegistry(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		writeSchema(t, root, name, content)
+	}
+	return root
+}
+
+func writeSchema(t *testing.T, root string, name string, content string) {
+	file := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	root := writeRegistry(t, registryFiles)
+	defer os.RemoveAll(root)
+	registry := NewRegistry(root)
+
+	for _, key := range []string{"shipment.json", "shipment", "./shipment.json"} {
+		schema, err := registry.Get(key)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", key, err)
+		}
+		if valid, err := ValidateCompiledBytes([]byte(`{"estimated_days": 2, "offset_days": 1}`), schema); !valid {
+			t.Errorf("%v: expected valid document, got %v", key, err)
+		}
+		if valid, _ := ValidateCompiledBytes([]byte(`{"estimated_days": 2, "offset_days": -1}`), schema); valid {
+			t.Errorf("%v: expected the referenced minimum to apply", key)
+		}
+	}
+	if _, err := registry.Get("https://schemas.example.com/days.json"); err != nil {
+		t.Errorf("expected the schema by $id, got %v", err)
+	}
+	if _, err := registry.Get("missing"); err == nil {
+		t.Errorf("expected an error for a missing schema")
+	}
+}
+
+func TestRegistryReload(t *testing.T) {
+	root := writeRegistry(t, registryFiles)
+	defer os.RemoveAll(root)
+	registry := NewRegistry(root)
+	if err := registry.Reload(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%4 == 0 {
+				if err := registry.Reload(); err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			schema, err := registry.Get("shipment")
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+				return
+			}
+			ValidateCompiledBytes([]byte(`{"estimated_days": 2}`), schema)
+		}(i)
+	}
+	wg.Wait()
+
+	writeSchema(t, root, "shipment.json", `{"type": "object", "required": ["estimated_days"]}`)
+	if err := registry.Reload(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	schema, _ := registry.Get("shipment")
+	if valid, _ := ValidateCompiledBytes([]byte(`{}`), schema); valid {
+		t.Errorf("expected the reloaded schema")
+	}
+
+	//Broken and remote schemas are reported and left out, the others are still served
+	writeSchema(t, root, "remote.json", `{"$ref": "https://other.example.com/schema.json"}`)
+	err := registry.Reload()
+	if loadError, ok := err.(*LoadError); !ok || len(loadError.Failures) != 1 || !strings.Contains(err.Error(), "remote reference") {
+		t.Errorf("expected the remote reference to be refused, got %v", err)
+	}
+	if _, err := registry.Get("remote"); err == nil || !strings.Contains(err.Error(), "remote reference") {
+		t.Errorf("expected the failure of remote.json, got %v", err)
+	}
+	writeSchema(t, root, "remote.json", `{"type": `)
+	if err := registry.Reload(); err == nil {
+		t.Errorf("expected an error for a broken schema")
+	}
+	if _, err := registry.Get("shipment"); err != nil {
+		t.Errorf("expected the other schemas to be served, got %v", err)
+	}
+
+	//A root that can't be walked keeps the loaded schemas
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatal(err)
+	}
+	current, _ := registry.Get("shipment")
+	if err := registry.Reload(); err == nil {
+		t.Errorf("expected an error for a missing root")
+	}
+	if kept, _ := registry.Get("shipment"); kept != current {
+		t.Errorf("expected the loaded schemas to be kept")
+	}
+}
+
+func TestRegistrySkipsFailingSchemas(t *testing.T) {
+	files := map[string]string{"broken.json": `{"type": `, "invalid.json": `{"type": 1}`}
+	for name, content := range registryFiles {
+		files[name] = content
+	}
+	root := writeRegistry(t, files)
+	defer os.RemoveAll(root)
+
+	registry := NewRegistry(root)
+	if _, err := registry.Get("shipment"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, key := range []string{"broken", "invalid.json"} {
+		if _, err := registry.Get(key); err == nil || strings.Contains(err.Error(), "not found") {
+			t.Errorf("%v: expected its compile error, got %v", key, err)
+		}
+	}
+}
+
+func TestRegistryResolvesRelativeRoot(t *testing.T) {
+	root := writeRegistry(t, registryFiles)
+	defer os.RemoveAll(root)
+	working, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(working)
+
+	if err := os.Chdir(filepath.Dir(root)); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry(filepath.Base(root))
+	if err := os.Chdir(working); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Get("shipment"); err != nil {
+		t.Errorf("expected the root of NewRegistry, got %v", err)
+	}
+}
+
+func TestRegistryLoadsOnceForConcurrentGets(t *testing.T) {
+	root := writeRegistry(t, registryFiles)
+	defer os.RemoveAll(root)
+	registry := NewRegistry(root)
+
+	schemas := make(chan *Schema, 8)
+	for i := 0; i < cap(schemas); i++ {
+		go func() {
+			schema, _ := registry.Get("shipment")
+			schemas <- schema
+		}()
+	}
+	first := <-schemas
+	for i := 1; i < cap(schemas); i++ {
+		if schema := <-schemas; schema == nil || schema != first {
+			t.Errorf("expected every Get to share the first load, got %p and %p", first, schema)
+		}
+	}
+}
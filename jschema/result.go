@@ -0,0 +1,230 @@
+package jschema
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Failure is one violation of the schema. The paths are JSON Pointers, the empty string
+// being the root. SchemaPath locates the failing Keyword in the schema document, while
+// EvaluationPath goes through the $ref keywords followed to reach it. Value is the
+// offending value as decoded by gojsonschema, with json.Number numbers.
+type Failure struct {
+	InstancePath   string      `json:"instance_path"`
+	SchemaPath     string      `json:"schema_path"`
+	EvaluationPath string      `json:"evaluation_path"`
+	Keyword        string      `json:"keyword"`
+	Message        string      `json:"message"`
+	Value          interface{} `json:"value"`
+}
+
+func (failure Failure) String() string {
+	path := failure.InstancePath
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + failure.Message
+}
+
+// Result is the outcome of a validation, failures keep the order of gojsonschema.
+type Result struct {
+	Valid    bool      `json:"valid"`
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+// ValidationError is returned for documents that don't follow the schema.
+type ValidationError struct {
+	Failures []Failure
+}
+
+func (err *ValidationError) Error() string {
+	var text strings.Builder
+	for _, failure := range err.Failures {
+		text.WriteString("- " + failure.String() + "\n")
+	}
+	return text.String()
+}
+
+// errorKeywords maps the gojsonschema error types to the keyword that produced them.
+var errorKeywords = map[string]string{
+	"false":                           "false",
+	"required":                        "required",
+	"invalid_type":                    "type",
+	"number_any_of":                   "anyOf",
+	"number_one_of":                   "oneOf",
+	"number_all_of":                   "allOf",
+	"number_not":                      "not",
+	"missing_dependency":              "dependencies",
+	"const":                           "const",
+	"enum":                            "enum",
+	"array_no_additional_items":       "additionalItems",
+	"array_min_items":                 "minItems",
+	"array_max_items":                 "maxItems",
+	"unique":                          "uniqueItems",
+	"contains":                        "contains",
+	"array_min_properties":            "minProperties",
+	"array_max_properties":            "maxProperties",
+	"additional_property_not_allowed": "additionalProperties",
+	"invalid_property_pattern":        "patternProperties",
+	"invalid_property_name":           "propertyNames",
+	"string_gte":                      "minLength",
+	"string_lte":                      "maxLength",
+	"pattern":                         "pattern",
+	"format":                          "format",
+	"multiple_of":                     "multipleOf",
+	"number_gte":                      "minimum",
+	"number_gt":                       "exclusiveMinimum",
+	"number_lte":                      "maximum",
+	"number_lt":                       "exclusiveMaximum",
+	"condition_then":                  "then",
+	"condition_else":                  "else",
+}
+
+func newResult(result *gojsonschema.Result, schema *Schema) Result {
+	converted := Result{Valid: result.Valid()}
+	for _, resultError := range result.Errors() {
+		tokens := strings.Split(resultError.Context().String("\x00"), "\x00")[1:] //Without (root)
+		keyword := errorKeywords[resultError.Type()]
+		failure := Failure{
+			InstancePath: pointer(tokens),
+			Keyword:      keyword,
+			Message:      resultError.Description(),
+			Value:        resultError.Value(),
+		}
+		if schema != nil && schema.Document != nil {
+			failure.EvaluationPath, failure.SchemaPath, _ = locate(schema.Document, schema.Document, "", "", tokens, keyword, 0)
+		}
+		converted.Failures = append(converted.Failures, failure)
+	}
+	return converted
+}
+
+func pointer(tokens []string) string {
+	var path strings.Builder
+	for _, token := range tokens {
+		path.WriteString("/" + escape(token))
+	}
+	return path.String()
+}
+
+// maxLocateDepth stops recursive references while locating a keyword.
+const maxLocateDepth = 32
+
+// locate returns the evaluation and schema paths of the subschema of node applying
+// keyword to the instance at tokens, searching properties, items and the applicators.
+// Non local references end the search at the $ref.
+func locate(root interface{}, node interface{}, evaluation string, path string, tokens []string, keyword string, depth int) (string, string, bool) {
+	if accept, isBool := node.(bool); isBool && !accept && keyword == "false" && len(tokens) == 0 {
+		return evaluation, path, true
+	}
+	object, ok := node.(map[string]interface{})
+	if !ok || depth > maxLocateDepth {
+		return "", "", false
+	}
+	if ref, isRef := object["$ref"].(string); isRef {
+		if !strings.HasPrefix(ref, "#") {
+			return evaluation + "/$ref", path + "/$ref", true
+		}
+		return locate(root, resolveRef(root, ref), evaluation+"/$ref", ref[1:], tokens, keyword, depth+1)
+	}
+
+	if len(tokens) == 0 {
+		if _, ok := object[keyword]; ok {
+			return evaluation + "/" + keyword, path + "/" + keyword, true
+		}
+	} else {
+		token := tokens[0]
+		var children []string
+		if properties, ok := object["properties"].(map[string]interface{}); ok {
+			if _, ok := properties[token]; ok {
+				children = append(children, "properties/"+escape(token))
+			}
+		}
+		if patterns, ok := object["patternProperties"].(map[string]interface{}); ok {
+			for pattern := range patterns {
+				if matched, _ := regexp.MatchString(pattern, token); matched {
+					children = append(children, "patternProperties/"+escape(pattern))
+				}
+			}
+		}
+		named := len(children) > 0
+		if arrayIndexPattern.MatchString(token) {
+			index, err := strconv.Atoi(token)
+			if items, ok := object["items"].([]interface{}); ok && err == nil && index < len(items) {
+				children = append(children, fmt.Sprintf("items/%v", index))
+			} else if ok {
+				children = append(children, "additionalItems")
+			} else {
+				children = append(children, "items")
+			}
+		}
+		if !named {
+			children = append(children, "additionalProperties") //An index-like token may be a key too
+		}
+		for _, child := range children {
+			if foundEvaluation, found, ok := locate(root, lookup(object, child), evaluation+"/"+child, path+"/"+child, tokens[1:], keyword, depth+1); ok {
+				return foundEvaluation, found, true
+			}
+		}
+	}
+
+	for _, applicator := range []string{"allOf", "anyOf", "oneOf"} {
+		branches, _ := object[applicator].([]interface{})
+		for i, branch := range branches {
+			branchPath := fmt.Sprintf("/%v/%v", applicator, i)
+			if foundEvaluation, found, ok := locate(root, branch, evaluation+branchPath, path+branchPath, tokens, keyword, depth+1); ok {
+				return foundEvaluation, found, true
+			}
+		}
+	}
+	for _, applicator := range []string{"then", "else", "not"} {
+		if foundEvaluation, found, ok := locate(root, object[applicator], evaluation+"/"+applicator, path+"/"+applicator, tokens, keyword, depth+1); ok {
+			return foundEvaluation, found, true
+		}
+	}
+	return "", "", false
+}
+
+// resolveRef returns the subschema of root named by a local reference.
+func resolveRef(root interface{}, ref string) interface{} {
+	target := root
+	for _, token := range strings.Split(strings.TrimPrefix(ref[1:], "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		parent, _ := target.(map[string]interface{})
+		target = parent[token]
+	}
+	return target
+}
+
+// arrayIndexPattern matches the pointer tokens that can be array indexes.
+var arrayIndexPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+// lookup follows a relative pointer whose tokens are escaped, nil when it goes past an
+// array.
+func lookup(object map[string]interface{}, relative string) interface{} {
+	var node interface{} = object
+	for _, token := range strings.Split(relative, "/") {
+		if items, ok := node.([]interface{}); ok {
+			index, err := strconv.Atoi(token)
+			if !arrayIndexPattern.MatchString(token) || err != nil || index >= len(items) {
+				return nil
+			}
+			node = items[index]
+			continue
+		}
+		parent, _ := node.(map[string]interface{})
+		node = parent[strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)]
+	}
+	return node
+}
+
+func escape(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
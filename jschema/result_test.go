@@ -0,0 +1,119 @@
+package jschema
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+var resultSchema = []byte(`{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["entity"],
+	"properties": {
+		"entity": {"type": "string"},
+		"metrics": {
+			"type": "object",
+			"properties": {
+				"lead_time/days": {"$ref": "#/definitions/days"},
+				"tags": {"type": "array", "items": {"type": "string"}}
+			},
+			"additionalProperties": false
+		}
+	},
+	"definitions": {"days": {"type": "integer", "minimum": 0}}
+}`)
+
+func TestCheckBytes(t *testing.T) {
+	schema, err := CompileBytes(resultSchema)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+
+	result, err := CheckBytes([]byte(`{"metrics": {"lead_time/days": -1, "tags": ["a", 2], "other": 1}}`), schema)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result.Valid {
+		t.Fatalf("expected an invalid result")
+	}
+
+	expected := map[string]Failure{
+		"required":             {InstancePath: "", SchemaPath: "/required", Keyword: "required"},
+		"minimum":              {InstancePath: "/metrics/lead_time~1days", SchemaPath: "/definitions/days/minimum", EvaluationPath: "/properties/metrics/properties/lead_time~1days/$ref/minimum", Keyword: "minimum"},
+		"type":                 {InstancePath: "/metrics/tags/1", SchemaPath: "/properties/metrics/properties/tags/items/type", Keyword: "type"},
+		"additionalProperties": {InstancePath: "/metrics", SchemaPath: "/properties/metrics/additionalProperties", Keyword: "additionalProperties"},
+	}
+	if len(result.Failures) != len(expected) {
+		t.Fatalf("expected %v failures, got %+v", len(expected), result.Failures)
+	}
+	for _, failure := range result.Failures {
+		want, ok := expected[failure.Keyword]
+		if want.EvaluationPath == "" {
+			want.EvaluationPath = want.SchemaPath
+		}
+		if !ok || failure.InstancePath != want.InstancePath || failure.SchemaPath != want.SchemaPath || failure.EvaluationPath != want.EvaluationPath || failure.Message == "" {
+			t.Errorf("unexpected failure %+v", failure)
+		}
+	}
+	for _, failure := range result.Failures {
+		if failure.Keyword == "minimum" && failure.Value != json.Number("-1") {
+			t.Errorf("expected the offending value, got %#v", failure.Value)
+		}
+	}
+
+	valid, err := ValidateCompiledBytes([]byte(`{"metrics": {}}`), schema)
+	var validationError *ValidationError
+	if valid || !errors.As(err, &validationError) || len(validationError.Failures) != 1 {
+		t.Errorf("expected a ValidationError, got %v", err)
+	}
+	//Without the schema document the failures are not located
+	valid, err = ValidateBytes([]byte(`{"metrics": {}}`), schema.Schema)
+	if valid || !errors.As(err, &validationError) || len(validationError.Failures) != 1 || validationError.Failures[0].SchemaPath != "" {
+		t.Errorf("expected a ValidationError without schema paths, got %v", err)
+	}
+	if _, err := CheckBytes([]byte(`{"entity": `), schema); err == nil {
+		t.Errorf("expected an error for a malformed document")
+	}
+}
+
+func TestValidateWritesNothing(t *testing.T) {
+	schema, err := CompileBytes(resultSchema)
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	ValidateCompiledBytes([]byte(`{"metrics": 1}`), schema)
+	os.Stdout = stdout
+	writer.Close()
+
+	if output, _ := ioutil.ReadAll(reader); len(output) > 0 {
+		t.Errorf("expected no output, got %q", output)
+	}
+}
+
+// TestCheckBytesIndexLikeKeys locates failures under keys that look like array indexes
+// but aren't valid ones.
+func TestCheckBytesIndexLikeKeys(t *testing.T) {
+	schema, err := CompileBytes([]byte(`{"items": [{}], "additionalProperties": {"type": "string"}}`))
+	if err != nil {
+		t.Fatalf("Error compiling schema %v", err)
+	}
+	for _, key := range []string{"-1", "01", "+1", "99999999999999999999"} {
+		result, err := CheckBytes([]byte(`{"`+key+`": 1}`), schema)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(result.Failures) != 1 || result.Failures[0].SchemaPath != "/additionalProperties/type" {
+			t.Errorf("%v: unexpected failures %+v", key, result.Failures)
+		}
+	}
+}
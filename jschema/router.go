@@ -0,0 +1,112 @@
+package jschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+// AnyVersion routes the documents of an entity whatever their version, when no route
+// names the version.
+const AnyVersion = "*"
+
+// Route sends the documents of an entity and version to a schema of the registry. An
+// empty Version routes the documents without version.
+type Route struct {
+	Entity  string `json:"entity"`
+	Version string `json:"version"`
+	Schema  string `json:"schema"`
+}
+
+// RoutingFile is the content of a routing file.
+type RoutingFile struct {
+	Routes []Route `json:"routes"`
+}
+
+// RouteError is returned for documents whose entity and version have no route, or
+// whose Field, entity or version, is not a string.
+type RouteError struct {
+	Entity  string
+	Version string
+	Field   string
+}
+
+func (err *RouteError) Error() string {
+	if err.Field != "" {
+		return fmt.Sprintf("jschema: the %v of the document is not a string", err.Field)
+	}
+	return fmt.Sprintf("jschema: no schema for entity %q and version %q", err.Entity, err.Version)
+}
+
+type routeKey struct {
+	entity  string
+	version string
+}
+
+// Router picks the schema of a document from its entity and version. Schemas are
+// looked up in the registry on every call, so reloads apply to the next document.
+type Router struct {
+	registry *Registry
+	routes   map[routeKey]string
+}
+
+// NewRouter checks that every route is unique and names a schema of the registry.
+func NewRouter(registry *Registry, routes []Route) (*Router, error) {
+	router := &Router{registry: registry, routes: make(map[routeKey]string, len(routes))}
+	for _, route := range routes {
+		key := routeKey{route.Entity, route.Version}
+		if route.Entity == "" {
+			return nil, fmt.Errorf("jschema: route to %v without entity", route.Schema)
+		}
+		if _, duplicated := router.routes[key]; duplicated {
+			return nil, fmt.Errorf("jschema: duplicated route for entity %q and version %q", route.Entity, route.Version)
+		}
+		if _, err := registry.Get(route.Schema); err != nil {
+			return nil, err
+		}
+		router.routes[key] = route.Schema
+	}
+	return router, nil
+}
+
+// LoadRouter reads the routes of a routing file.
+func LoadRouter(registry *Registry, path string) (*Router, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file RoutingFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("jschema: %v: %v", path, err)
+	}
+	return NewRouter(registry, file.Routes)
+}
+
+// Schema returns the schema of a document, reading its entity and version without
+// decoding the rest of it.
+func (router *Router) Schema(doc []byte) (*Schema, error) {
+	keys := []string{"entity", "version"}
+	fields := make([]string, len(keys))
+	for i, value := range bic.PeekValues(doc, keys...) {
+		var isString bool
+		if fields[i], isString = value.(string); !isString && value != nil {
+			return nil, &RouteError{Field: keys[i]}
+		}
+	}
+	return router.Route(fields[0], fields[1])
+}
+
+// Route returns the schema of an entity and version, falling back to the AnyVersion
+// route of the entity.
+func (router *Router) Route(entity string, version string) (*Schema, error) {
+	name, ok := router.routes[routeKey{entity, version}]
+	if !ok {
+		name, ok = router.routes[routeKey{entity, AnyVersion}]
+	}
+	if !ok {
+		return nil, &RouteError{Entity: entity, Version: version}
+	}
+	return router.registry.Get(name)
+}
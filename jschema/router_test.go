@@ -0,0 +1,76 @@
+package jschema
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	root := writeRegistry(t, registryFiles)
+	defer os.RemoveAll(root)
+	registry := NewRegistry(root)
+
+	router, err := NewRouter(registry, []Route{
+		{Entity: "SHIPMENT", Version: "1.0", Schema: "shipment"},
+		{Entity: "SHIPMENT", Version: AnyVersion, Schema: "common/days.json"},
+		{Entity: "ORDER", Schema: "shipment.json"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	shipment, _ := registry.Get("shipment")
+	days, _ := registry.Get("common/days")
+
+	tests := []struct {
+		doc    string
+		schema *Schema
+	}{
+		{`{"metrics": {"version": "2.0"}, "entity": "SHIPMENT", "version": "1.0"}`, shipment},
+		{`{"entity": "SHIPMENT", "version": "2.0"}`, days},
+		{`{"entity": "SHIPMENT"}`, days},
+		{`{"entity": "ORDER"}`, shipment},
+		{`{"entity": "ORDER", "version": "1.0"}`, nil},
+		{`{"entity": "order"}`, nil},
+		{`{"id": "1"}`, nil},
+		{`{"entity": "SHIPMENT", "version": 1}`, nil},
+		{`{"entity": "SHIPMENT", "version": {"major": 1}}`, nil},
+		{`{"entity": ["SHIPMENT"]}`, nil},
+	}
+	for _, test := range tests {
+		schema, err := router.Schema([]byte(test.doc))
+		if schema != test.schema {
+			t.Errorf("%v: unexpected schema, error %v", test.doc, err)
+		}
+		var routeError *RouteError
+		if test.schema == nil && !errors.As(err, &routeError) {
+			t.Errorf("%v: expected a RouteError, got %v", test.doc, err)
+		}
+	}
+
+	if _, err := NewRouter(registry, []Route{{Entity: "A", Schema: "shipment"}, {Entity: "A", Schema: "shipment"}}); err == nil {
+		t.Errorf("expected an error for duplicated routes")
+	}
+	if _, err := NewRouter(registry, []Route{{Entity: "A", Schema: "missing"}}); err == nil {
+		t.Errorf("expected an error for an unknown schema")
+	}
+}
+
+func TestLoadRouter(t *testing.T) {
+	router, err := LoadRouter(NewRegistry("."), "../routing.json")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	document, err := ioutil.ReadFile("../document.json")
+	if err != nil {
+		t.Fatalf("Error reading document %v", err)
+	}
+	schema, err := router.Schema(document)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if valid, err := ValidateCompiledBytes(document, schema); !valid {
+		t.Errorf("expected document.json to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package jschema
+
+import (
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Schema is a compiled JSON Schema along with the document it was compiled from, which
+// locates the keyword behind every failure. Numbers of the document are json.Number.
+type Schema struct {
+	*gojsonschema.Schema
+	Document interface{}
+}
+
+// Compile reads and compiles the schema of loader.
+func Compile(loader gojsonschema.JSONLoader) (*Schema, error) {
+	document, err := loader.LoadJSON()
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := gojsonschema.NewSchemaLoader().Compile(loader)
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{Schema: compiled, Document: document}, nil
+}
+
+// CompileBytes compiles a schema held in memory.
+func CompileBytes(source []byte) (*Schema, error) {
+	return Compile(gojsonschema.NewBytesLoader(source))
+}
@@ -0,0 +1,37 @@
+package stopwatch
+
+import (
+	"context"
+	"time"
+)
+
+type contextKey struct{}
+
+type contextTimer struct {
+	threshold time.Duration
+	fn        TimerFunc
+}
+
+// WithTimer returns a copy of ctx carrying a callback for long-running work. Code that
+// calls Track on the context will invoke fn, once, if it is still running after
+// threshold.
+func WithTimer(ctx context.Context, threshold time.Duration, fn TimerFunc) context.Context {
+	return context.WithValue(ctx, contextKey{}, contextTimer{threshold, fn})
+}
+
+// Track starts measuring the work bound to ctx and returns the function that must be
+// called when the work is done. It is a no-op when ctx carries no timer.
+func Track(ctx context.Context) (done func()) {
+	timer, ok := ctx.Value(contextKey{}).(contextTimer)
+	if !ok || timer.fn == nil {
+		return func() {}
+	}
+
+	start := now()
+	afterFunc := time.AfterFunc(timer.threshold, func() {
+		timer.fn(StartAt(start).Stop())
+	})
+	return func() {
+		afterFunc.Stop()
+	}
+}
@@ -0,0 +1,45 @@
+package stopwatch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTrackCallsTimerForSlowWork(t *testing.T) {
+	called := make(chan Watch, 1)
+	ctx := WithTimer(context.Background(), time.Millisecond, func(w Watch) {
+		called <- w
+	})
+
+	done := Track(ctx)
+	defer done()
+
+	select {
+	case w := <-called:
+		if w.String() == "0m0.00s" {
+			t.Error("expected a stopped watch")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timer was not called")
+	}
+}
+
+func TestTrackSkipsFastWork(t *testing.T) {
+	called := make(chan Watch, 1)
+	ctx := WithTimer(context.Background(), time.Hour, func(w Watch) {
+		called <- w
+	})
+
+	Track(ctx)()
+
+	select {
+	case <-called:
+		t.Error("timer should not be called for fast work")
+	default:
+	}
+}
+
+func TestTrackWithoutTimer(t *testing.T) {
+	Track(context.Background())()
+}
@@ -0,0 +1,195 @@
+package validation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+)
+
+// Engine names a validator in the disagreement reports.
+type Engine struct {
+	Name      string
+	Validator Validator
+}
+
+// Disagreement is a document accepted by one engine and rejected by the other.
+type Disagreement struct {
+	ID            string `json:"id"`
+	Entity        string `json:"entity,omitempty"`
+	Primary       string `json:"primary"`
+	Shadow        string `json:"shadow"`
+	PrimaryResult Result `json:"primary_result"`
+	ShadowResult  Result `json:"shadow_result"`
+}
+
+// DefaultMaxPending is the MaxPending of the shadows built by NewShadow.
+var DefaultMaxPending = 4 * runtime.GOMAXPROCS(0)
+
+// Shadow validates every document with both engines and returns the verdict of the
+// primary one as soon as it is known. The shadow engine runs in the background, call
+// Wait before closing the report. At most MaxPending shadow validations run at once,
+// past them documents are only validated by the primary engine and counted by Dropped.
+type Shadow struct {
+	Primary    Engine
+	Shadow     Engine
+	Report     *Report
+	MaxPending int
+
+	slotsOnce sync.Once
+	slots     chan struct{}
+	pending   sync.WaitGroup
+	mutex     sync.Mutex
+	dropped   int
+	err       error
+}
+
+func NewShadow(primary Engine, shadow Engine, report *Report) *Shadow {
+	return &Shadow{Primary: primary, Shadow: shadow, Report: report, MaxPending: DefaultMaxPending}
+}
+
+func (shadow *Shadow) Validate(ctx context.Context, doc []byte) Result {
+	shadow.slotsOnce.Do(func() { shadow.slots = make(chan struct{}, shadow.MaxPending) })
+	select {
+	case shadow.slots <- struct{}{}:
+	default:
+		//A slow shadow engine must not hold up the primary one, nor pile up goroutines
+		shadow.mutex.Lock()
+		shadow.dropped++
+		shadow.mutex.Unlock()
+		return shadow.Primary.Validator.Validate(ctx, doc)
+	}
+
+	primaryResult := make(chan Result, 1)
+	shadow.pending.Add(1)
+	go func() {
+		defer shadow.pending.Done()
+		defer func() { <-shadow.slots }()
+		//The comparison outlives the call, so only the values of ctx are kept
+		secondary := shadow.Shadow.Validator.Validate(detachedContext{ctx}, doc)
+		shadow.compare(doc, <-primaryResult, secondary)
+	}()
+
+	primary := shadow.Primary.Validator.Validate(ctx, doc)
+	primaryResult <- primary
+	return primary
+}
+
+func (shadow *Shadow) compare(doc []byte, primary Result, secondary Result) {
+	if primary.Valid == secondary.Valid || interrupted(primary) || interrupted(secondary) {
+		return
+	}
+	disagreement := Disagreement{
+		Primary:       shadow.Primary.Name,
+		Shadow:        shadow.Shadow.Name,
+		PrimaryResult: primary,
+		ShadowResult:  secondary,
+	}
+	disagreement.ID, disagreement.Entity = bic.PeekEnvelope(doc)
+	if err := shadow.Report.Add(disagreement); err != nil {
+		shadow.mutex.Lock()
+		if shadow.err == nil {
+			shadow.err = err
+		}
+		shadow.mutex.Unlock()
+	}
+}
+
+// Wait blocks until the pending comparisons are reported and returns the first error
+// writing them.
+func (shadow *Shadow) Wait() error {
+	shadow.pending.Wait()
+	shadow.mutex.Lock()
+	defer shadow.mutex.Unlock()
+	return shadow.err
+}
+
+// Dropped returns the number of documents the shadow engine skipped because MaxPending
+// validations were running.
+func (shadow *Shadow) Dropped() int {
+	shadow.mutex.Lock()
+	defer shadow.mutex.Unlock()
+	return shadow.dropped
+}
+
+// detachedContext keeps the values of a context without its deadline and cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (ctx detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (ctx detachedContext) Done() <-chan struct{}             { return nil }
+func (ctx detachedContext) Err() error                        { return nil }
+func (ctx detachedContext) Value(key interface{}) interface{} { return ctx.parent.Value(key) }
+
+// interrupted reports results cut short by the context, they say nothing about the document.
+func interrupted(result Result) bool {
+	return result.Code == "validation_timeout" || result.Code == "validation_canceled"
+}
+
+// Report writes disagreements as newline-delimited JSON. It is safe for concurrent use.
+type Report struct {
+	mutex   sync.Mutex
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	closer  io.Closer
+	count   int
+	err     error
+}
+
+func NewReport(w io.Writer) *Report {
+	writer := bufio.NewWriter(w)
+	return &Report{writer: writer, encoder: json.NewEncoder(writer)}
+}
+
+// CreateReport truncates or creates the file at path and writes the report to it.
+func CreateReport(path string) (*Report, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	report := NewReport(file)
+	report.closer = file
+	return report, nil
+}
+
+// Add writes one disagreement. After the first write error every call returns it.
+func (report *Report) Add(disagreement Disagreement) error {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	if report.err != nil {
+		return report.err
+	}
+	if report.err = report.encoder.Encode(disagreement); report.err == nil {
+		report.count++
+	}
+	return report.err
+}
+
+// Count returns the number of disagreements written.
+func (report *Report) Count() int {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	return report.count
+}
+
+// Close flushes the report and closes its file when it was created by CreateReport.
+func (report *Report) Close() error {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	if report.err == nil {
+		report.err = report.writer.Flush()
+	}
+	if report.closer != nil {
+		if err := report.closer.Close(); report.err == nil {
+			report.err = err
+		}
+	}
+	return report.err
+}
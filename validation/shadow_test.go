@@ -0,0 +1,199 @@
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The config accepts documents without version, the schema requires it
+var versionless = []byte(`{"entity": "SHIPMENT_TEST", "id": "42", "metrics": {"handling_time": {"date_from": "2019-10-11T13:38:29-03:00", "estimated_days": 1}, "lead_time": {"estimated_days": 3, "shipping_offset_days": 2}}}`)
+
+func newShadow(t *testing.T, report *Report) *Shadow {
+	engines := loadEngines(t)
+	return NewShadow(
+		Engine{Name: EngineBic, Validator: engines[EngineBic]},
+		Engine{Name: EngineJSONSchema, Validator: engines[EngineJSONSchema]},
+		report,
+	)
+}
+
+func TestShadowReportsDisagreements(t *testing.T) {
+	var output bytes.Buffer
+	report := NewReport(&output)
+	shadow := newShadow(t, report)
+
+	document, err := ioutil.ReadFile("../document.json")
+	if err != nil {
+		t.Fatalf("Error reading document %v", err)
+	}
+	if result := shadow.Validate(context.Background(), document); !result.Valid {
+		t.Errorf("expected the primary verdict, got %+v", result)
+	}
+	if result := shadow.Validate(context.Background(), versionless); !result.Valid {
+		t.Errorf("expected the primary verdict, got %+v", result)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	shadow.Validate(canceled, versionless)
+
+	if err := shadow.Wait(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := report.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if report.Count() != 1 {
+		t.Fatalf("expected 1 disagreement, got %v: %v", report.Count(), output.String())
+	}
+
+	var disagreement Disagreement
+	if err := json.Unmarshal(output.Bytes(), &disagreement); err != nil {
+		t.Fatalf("Error reading report %v", err)
+	}
+	if disagreement.ID != "42" || disagreement.Entity != "SHIPMENT_TEST" || disagreement.Primary != EngineBic || disagreement.Shadow != EngineJSONSchema {
+		t.Errorf("unexpected disagreement %+v", disagreement)
+	}
+	if !disagreement.PrimaryResult.Valid || disagreement.ShadowResult.Valid || len(disagreement.ShadowResult.Errors) == 0 {
+		t.Errorf("expected both error sets, got %+v", disagreement)
+	}
+	if !strings.Contains(strings.Join(disagreement.ShadowResult.Errors, " "), "version") {
+		t.Errorf("expected the schema failure, got %v", disagreement.ShadowResult.Errors)
+	}
+}
+
+func TestCreateReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shadow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "disagreements.jsonl")
+	report, err := CreateReport(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	shadow := newShadow(t, report)
+	shadow.MaxPending = 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			shadow.Validate(context.Background(), versionless)
+		}()
+	}
+	wg.Wait()
+	if err := shadow.Wait(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := report.Close(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading report %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); len(lines) != 10 {
+		t.Errorf("expected 10 disagreements, got %v", len(lines))
+	}
+}
+
+func TestShadowReturnsBeforeTheShadowEngine(t *testing.T) {
+	release := make(chan bool)
+	report := NewReport(ioutil.Discard)
+	shadow := NewShadow(
+		Engine{Name: "primary", Validator: Func(func(ctx context.Context, doc []byte) Result { return Result{Valid: true} })},
+		Engine{Name: "shadow", Validator: Func(func(ctx context.Context, doc []byte) Result {
+			<-release
+			return Result{Code: "unprocessable_entity"}
+		})},
+		report,
+	)
+
+	if result := shadow.Validate(context.Background(), versionless); !result.Valid {
+		t.Errorf("expected the primary verdict, got %+v", result)
+	}
+	close(release)
+	if err := shadow.Wait(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if report.Count() != 1 {
+		t.Errorf("expected 1 disagreement, got %v", report.Count())
+	}
+}
+
+func TestShadowDropsPastMaxPending(t *testing.T) {
+	release := make(chan bool)
+	started := make(chan bool, 10)
+	report := NewReport(ioutil.Discard)
+	shadow := NewShadow(
+		Engine{Name: "primary", Validator: Func(func(ctx context.Context, doc []byte) Result { return Result{Valid: true} })},
+		Engine{Name: "shadow", Validator: Func(func(ctx context.Context, doc []byte) Result {
+			started <- true
+			<-release
+			return Result{Code: "unprocessable_entity"}
+		})},
+		report,
+	)
+	shadow.MaxPending = 2
+
+	for i := 0; i < 5; i++ {
+		if result := shadow.Validate(context.Background(), versionless); !result.Valid {
+			t.Errorf("expected the primary verdict, got %+v", result)
+		}
+	}
+	if shadow.Dropped() != 3 {
+		t.Errorf("expected 3 dropped documents, got %v", shadow.Dropped())
+	}
+	<-started
+	<-started
+	close(release)
+	if err := shadow.Wait(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if report.Count() != 2 {
+		t.Errorf("expected 2 disagreements, got %v", report.Count())
+	}
+
+	//The slots are free again
+	shadow.Validate(context.Background(), versionless)
+	if err := shadow.Wait(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if shadow.Dropped() != 3 || report.Count() != 3 {
+		t.Errorf("expected 3 dropped documents and 3 disagreements, got %v and %v", shadow.Dropped(), report.Count())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestShadowReportsWriteErrors(t *testing.T) {
+	writer := bufio.NewWriterSize(failingWriter{}, 16) //Small enough for the disagreement to reach failingWriter
+	report := &Report{writer: writer, encoder: json.NewEncoder(writer)}
+	shadow := newShadow(t, report)
+
+	shadow.Validate(context.Background(), versionless)
+	if err := shadow.Wait(); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the write error, got %v", err)
+	}
+	if report.Count() != 0 {
+		t.Errorf("failed writes should not be counted, got %v", report.Count())
+	}
+}
@@ -0,0 +1,150 @@
+// Package validation drives the bic and jschema engines through one interface, so
+// benchmarks and commands can choose the engine with a flag.
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mercadolibre/go-meli-toolkit/goutils/apierrors"
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/mercadolibre/jsonschema_test/jschema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	EngineBic        = "bic"     //bic interpreter, bic.Validator
+	EngineStream     = "stream"  //bic single pass validator, bic.StreamValidator
+	EngineJSONSchema = "jschema" //JSON Schema through gojsonschema
+)
+
+// Result is the outcome of validating one document, whatever the engine. Code, Status
+// and Message follow the apierrors conventions, Errors has one entry per failure.
+type Result struct {
+	Valid   bool     `json:"valid"`
+	Code    string   `json:"code,omitempty"`
+	Status  int      `json:"status,omitempty"`
+	Message string   `json:"message,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	// Err is the error returned by the engine
+	Err error `json:"-"`
+}
+
+type Validator interface {
+	Validate(ctx context.Context, doc []byte) Result
+}
+
+// Func adapts a function to the Validator interface.
+type Func func(ctx context.Context, doc []byte) Result
+
+func (fn Func) Validate(ctx context.Context, doc []byte) Result {
+	return fn(ctx, doc)
+}
+
+// FromError builds the result of an engine returning (bool, error). apierrors.ApiError
+// keeps its fields, context errors become 408 and anything else is a 422.
+func FromError(valid bool, err error) Result {
+	if err == nil {
+		return Result{Valid: valid}
+	}
+
+	result := Result{Err: err}
+	switch err := err.(type) {
+	case apierrors.ApiError:
+		result.Code = err.Code()
+		result.Status = err.Status()
+		result.Message = err.Message()
+		for _, cause := range err.Cause() {
+			result.Errors = append(result.Errors, fmt.Sprintf("%v", cause))
+		}
+		return result
+	}
+
+	switch err {
+	case context.DeadlineExceeded:
+		result.Code, result.Status = "validation_timeout", http.StatusRequestTimeout
+	case context.Canceled:
+		result.Code, result.Status = "validation_canceled", http.StatusRequestTimeout
+	default:
+		result.Code, result.Status = "unprocessable_entity", http.StatusUnprocessableEntity
+	}
+	result.Message = err.Error()
+	return result
+}
+
+// Bic validates with the bic interpreter.
+func Bic(config *bic.StructProducerConfig, log bic.Logger) Validator {
+	validator := bic.NewValidator(config, log)
+	return Func(func(ctx context.Context, doc []byte) Result {
+		return FromError(validator.ValidateContext(ctx, doc))
+	})
+}
+
+// BicStream validates with the bic single pass validator.
+func BicStream(config *bic.StructProducerConfig, log bic.Logger) Validator {
+	validator := bic.NewStreamValidator(config, log)
+	return Func(func(ctx context.Context, doc []byte) Result {
+		return FromError(validator.ValidateContext(ctx, doc))
+	})
+}
+
+// JSONSchema validates against a compiled schema. Every schema failure is one entry of
+// Errors, documents that can't be read are reported as a 400.
+func JSONSchema(schema *jschema.Schema) Validator {
+	return Func(func(ctx context.Context, doc []byte) Result {
+		valid, err := jschema.ValidateBytesContext(ctx, doc, schema)
+		var validationError *jschema.ValidationError
+		if _, isApiError := err.(apierrors.ApiError); err == nil || isApiError {
+			return FromError(valid, err)
+		} else if !errors.As(err, &validationError) {
+			return Result{Code: "bad_request", Status: http.StatusBadRequest, Message: err.Error(), Err: err}
+		}
+
+		result := Result{Code: "unprocessable_entity", Status: http.StatusUnprocessableEntity, Message: "the document is not valid", Err: err}
+		for _, failure := range validationError.Failures {
+			result.Errors = append(result.Errors, failure.String())
+		}
+		return result
+	})
+}
+
+// JSONSchemaRouter validates against the schema routed by the entity and version of
+// each document. Documents without a route are reported as a 404, routed schemas that
+// can't be loaded as a 500.
+func JSONSchemaRouter(router *jschema.Router) Validator {
+	return Func(func(ctx context.Context, doc []byte) Result {
+		schema, err := router.Schema(doc)
+		var routeError *jschema.RouteError
+		if errors.As(err, &routeError) {
+			return FromError(false, apierrors.NewNotFoundApiError(err.Error()))
+		} else if err != nil {
+			return FromError(false, apierrors.NewInternalServerApiError("loading the routed schema", err))
+		}
+		return JSONSchema(schema).Validate(ctx, doc)
+	})
+}
+
+// Load builds the validator of an engine. bic engines read the producer config from
+// configPath, jschema compiles the schema referenced by schemaURI.
+func Load(engine string, configPath string, schemaURI string) (Validator, error) {
+	switch engine {
+	case EngineBic, EngineStream:
+		config, err := bic.GetProducerConfigFromFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config %v", err)
+		}
+		if engine == EngineStream {
+			return BicStream(config, nil), nil
+		}
+		return Bic(config, nil), nil
+	case EngineJSONSchema:
+		schema, err := jschema.Compile(gojsonschema.NewReferenceLoader(schemaURI))
+		if err != nil {
+			return nil, fmt.Errorf("error reading schema %v", err)
+		}
+		return JSONSchema(schema), nil
+	}
+	return nil, fmt.Errorf("unknown engine %q, expected %v, %v or %v", engine, EngineBic, EngineStream, EngineJSONSchema)
+}
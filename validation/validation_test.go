@@ -0,0 +1,150 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mercadolibre/jsonschema_test/bic"
+	"github.com/mercadolibre/jsonschema_test/jschema"
+)
+
+func loadEngines(tb testing.TB) map[string]Validator {
+	previousLogger := bic.DefaultLogger
+	bic.DefaultLogger = bic.NopLogger{}
+	tb.Cleanup(func() { bic.DefaultLogger = previousLogger }) //Restored once the calling test is done
+	validators := make(map[string]Validator)
+	for _, engine := range []string{EngineBic, EngineStream, EngineJSONSchema} {
+		validator, err := Load(engine, "../bic/config-productor.json", "file://../jschema/schema.json")
+		if err != nil {
+			tb.Fatalf("Error loading %v: %v", engine, err)
+		}
+		validators[engine] = validator
+	}
+	return validators
+}
+
+func TestEnginesShareResults(t *testing.T) {
+	document, err := ioutil.ReadFile("../document.json")
+	if err != nil {
+		t.Fatalf("Error reading document %v", err)
+	}
+	invalid := []byte(`{"entity": "SHIPMENT_TEST", "id": "1", "version": "0.0.1", "metrics": {"handling_time": {"estimated_days": "three"}}}`)
+
+	for engine, validator := range loadEngines(t) {
+		if result := validator.Validate(context.Background(), document); !result.Valid || result.Err != nil {
+			t.Errorf("%v: expected document.json to be valid, got %+v", engine, result)
+		}
+
+		result := validator.Validate(context.Background(), invalid)
+		if result.Valid || result.Err == nil || result.Code == "" || result.Status == 0 || len(result.Errors) == 0 {
+			t.Errorf("%v: expected a complete error result, got %+v", engine, result)
+		}
+
+		if result := validator.Validate(context.Background(), []byte(`{"id": `)); result.Valid || result.Status == 0 {
+			t.Errorf("%v: expected malformed json to be rejected, got %+v", engine, result)
+		}
+
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+		if result := validator.Validate(canceled, document); result.Code != "validation_canceled" || result.Status != 408 {
+			t.Errorf("%v: expected a canceled result, got %+v", engine, result)
+		}
+	}
+}
+
+func TestFromError(t *testing.T) {
+	if result := FromError(true, nil); !result.Valid || result.Code != "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	apiError := bic.NewUnprocessableEntityApiError("invalid payload", errors.New("bad leaf"))
+	if result := FromError(false, apiError); result.Code != apiError.Code() || result.Status != 422 || result.Message != "invalid payload" || len(result.Errors) != 1 || result.Errors[0] != "bad leaf" {
+		t.Errorf("expected the api error fields, got %+v", result)
+	}
+
+	for err, code := range map[error]string{
+		context.DeadlineExceeded: "validation_timeout",
+		context.Canceled:         "validation_canceled",
+		errors.New("other"):      "unprocessable_entity",
+	} {
+		if result := FromError(false, err); result.Code != code || result.Err != err || result.Message != err.Error() {
+			t.Errorf("%v: unexpected result %+v", err, result)
+		}
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	for _, engine := range []string{EngineBic, EngineJSONSchema} {
+		if result := loadEngines(t)[engine].Validate(expired, []byte(`{}`)); result.Code != "validation_timeout" || result.Status != http.StatusRequestTimeout {
+			t.Errorf("%v: expected a timeout, got %+v", engine, result)
+		}
+	}
+}
+
+func TestLoadUnknownEngine(t *testing.T) {
+	if _, err := Load("xml", "", ""); err == nil {
+		t.Errorf("expected an error for an unknown engine")
+	}
+	if _, err := Load(EngineBic, "missing.json", ""); err == nil {
+		t.Errorf("expected an error for a missing config")
+	}
+}
+
+func BenchmarkEngines(b *testing.B) {
+	document, err := ioutil.ReadFile("../document.json")
+	if err != nil {
+		b.Fatalf("Error reading document %v", err)
+	}
+	for engine, validator := range loadEngines(b) {
+		b.Run(engine, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				validator.Validate(context.Background(), document)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaRouterStatuses(t *testing.T) {
+	root, err := ioutil.TempDir("", "router")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	schemaPath := filepath.Join(root, "shipment.json")
+	if err := ioutil.WriteFile(schemaPath, []byte(`{"type": "object"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := jschema.NewRegistry(root)
+	router, err := jschema.NewRouter(registry, []jschema.Route{{Entity: "SHIPMENT", Version: jschema.AnyVersion, Schema: "shipment"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	validator := JSONSchemaRouter(router)
+
+	statuses := map[string]int{
+		`{"entity": "SHIPMENT", "version": "1"}`: 0,
+		`{"entity": "ORDER", "version": "1"}`:    http.StatusNotFound,
+		`{"entity": "SHIPMENT", "version": 1}`:   http.StatusNotFound,
+	}
+	for doc, status := range statuses {
+		if result := validator.Validate(context.Background(), []byte(doc)); result.Status != status {
+			t.Errorf("%v: expected status %v, got %+v", doc, status, result)
+		}
+	}
+
+	os.Remove(schemaPath)
+	if err := registry.Reload(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if result := validator.Validate(context.Background(), []byte(`{"entity": "SHIPMENT"}`)); result.Status != http.StatusInternalServerError {
+		t.Errorf("expected a 500 for a routed schema that can't be loaded, got %+v", result)
+	}
+}